@@ -0,0 +1,220 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-core/tokget/internal/cache"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/prompt"
+)
+
+// redacted replaces tokens in the output of the command "cache show" unless secrets are requested.
+const redacted = "<redacted>"
+
+// cacheOptions are flags of the token cache.
+type cacheOptions struct {
+	enabled    bool
+	filename   string
+	skew       time.Duration
+	keyEnv     string
+	keyFile    string
+	passphrase bool
+}
+
+// cacheFlags defines flags of the token cache.
+func cacheFlags(fs *flag.FlagSet, opts *cacheOptions) {
+	fs.BoolVar(&opts.enabled, "cache", false, "reuse tokens from the token cache until they expire, and refresh them by a refresh token")
+	cacheUseFlags(fs, opts)
+}
+
+// cacheUseFlags defines flags of the token cache for commands that always use the cache.
+func cacheUseFlags(fs *flag.FlagSet, opts *cacheOptions) {
+	fs.DurationVar(&opts.skew, "cache-skew", time.Minute, "a time before the tokens' expiration when cached tokens are not used anymore")
+	cacheFileFlags(fs, opts)
+}
+
+// cacheFileFlags defines flags of the token cache's file and its encryption key.
+func cacheFileFlags(fs *flag.FlagSet, opts *cacheOptions) {
+	fs.StringVar(&opts.filename, "cache-file", cache.DefaultFilename(), "a token cache file")
+	fs.StringVar(&opts.keyEnv, "cache-key-env", "", "an environment variable that contains a key to encrypt the token cache (a cache key is required)")
+	fs.StringVar(&opts.keyFile, "cache-key-file", "", "a file that contains a key to encrypt the token cache (a cache key is required)")
+	fs.BoolVar(&opts.passphrase, "cache-passphrase", false, "request a passphrase to encrypt the token cache (a cache key is required)")
+}
+
+// open returns the token cache that is encrypted by a cache key.
+//
+// The cache contains tokens, so the function returns an error when no cache key is defined
+// instead of storing the tokens in plaintext.
+func (opts *cacheOptions) open() (*cache.Cache, error) {
+	var secret []byte
+	switch {
+	case opts.keyEnv != "":
+		v, ok := os.LookupEnv(opts.keyEnv)
+		if !ok || v == "" {
+			return nil, errors.New(errors.KindCacheKeyInvalid, "environment variable %q does not contain a cache key", opts.keyEnv)
+		}
+		secret = []byte(v)
+	case opts.keyFile != "":
+		b, err := ioutil.ReadFile(opts.keyFile)
+		if err != nil {
+			return nil, errors.New(errors.KindCacheKeyInvalid, err, "read cache key file")
+		}
+		if secret = []byte(strings.TrimSpace(string(b))); len(secret) == 0 {
+			return nil, errors.New(errors.KindCacheKeyInvalid, "cache key file %q is empty", opts.keyFile)
+		}
+	case opts.passphrase:
+		b, err := prompt.Password("Enter cache passphrase: ")
+		if err != nil {
+			return nil, errors.Wrap(err, "read cache passphrase")
+		}
+		if secret = b; len(secret) == 0 {
+			return nil, errors.New(errors.KindCacheKeyInvalid, "cache passphrase is empty")
+		}
+	default:
+		return nil, errors.New(errors.KindCacheKeyInvalid, "cache key is missed: use --cache-key-env, --cache-key-file or --cache-passphrase")
+	}
+	return cache.New(opts.filename, secret), nil
+}
+
+// cacheFilter selects cached tokens.
+type cacheFilter struct {
+	issuer   string
+	clientID string
+	username string
+	expired  bool
+}
+
+// cacheFilterFlags defines flags that select cached tokens.
+func cacheFilterFlags(fs *flag.FlagSet, f *cacheFilter) {
+	fs.StringVar(&f.issuer, "e", "", "select tokens of an OpenID Connect endpoint (issuer)")
+	fs.StringVar(&f.clientID, "c", "", "select tokens of an OpenID Connect client ID")
+	fs.StringVar(&f.username, "u", "", "select tokens of a user's name")
+	fs.BoolVar(&f.expired, "expired", false, "select expired tokens only")
+}
+
+// match returns true when cached tokens match the filter.
+func (f *cacheFilter) match(e *cache.Entry) bool {
+	switch {
+	case f.issuer != "" && strings.TrimSuffix(f.issuer, "/") != e.Issuer:
+		return false
+	case f.clientID != "" && f.clientID != e.ClientID:
+		return false
+	case f.username != "" && f.username != e.Username:
+		return false
+	case f.expired && e.Valid(time.Now(), 0):
+		return false
+	}
+	return true
+}
+
+// runCache runs a subcommand of the command cache: list, show or purge.
+//
+// The subcommands list and purge never print tokens. The subcommand show prints tokens only with the flag --secrets.
+func runCache(out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("cache subcommand is missed: list, show or purge")
+	}
+	switch args[0] {
+	case "list", "show", "purge":
+	default:
+		return errors.New("%q is not valid cache subcommand", args[0])
+	}
+	var (
+		opts    cacheOptions
+		filter  cacheFilter
+		secrets bool
+	)
+	fs := flag.NewFlagSet("cache "+args[0], flag.ExitOnError)
+	cacheFileFlags(fs, &opts)
+	cacheFilterFlags(fs, &filter)
+	if args[0] == "show" {
+		fs.BoolVar(&secrets, "secrets", false, "print tokens (they are redacted by default)")
+	}
+	fs.Parse(args[1:])
+
+	c, err := opts.open()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "purge" {
+		n, err := c.Delete(filter.match)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d cached tokens are removed\n", n)
+		return nil
+	}
+
+	entries, err := c.Entries()
+	if err != nil {
+		return err
+	}
+	var selected []*cache.Entry
+	for _, e := range entries {
+		if filter.match(e) {
+			selected = append(selected, e)
+		}
+	}
+
+	if args[0] == "show" {
+		shown := make([]*cache.Entry, 0, len(selected))
+		for _, e := range selected {
+			e := *e
+			if !secrets {
+				e.AccessToken = redact(e.AccessToken)
+				e.IDToken = redact(e.IDToken)
+				e.RefreshToken = redact(e.RefreshToken)
+			}
+			shown = append(shown, &e)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(shown); err != nil {
+			return errors.Wrap(err, "encode cached tokens to JSON")
+		}
+		return nil
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "issuer\tclient\tusername\tscopes\taudience\texpiry\trefresh token")
+	for _, e := range selected {
+		expiry := "unknown"
+		if !e.Expiry.IsZero() {
+			expiry = e.Expiry.Local().Format(time.RFC3339)
+			if !e.Valid(now, 0) {
+				expiry += " (expired)"
+			}
+		}
+		refresh := "no"
+		if e.RefreshToken != "" {
+			refresh = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Issuer, e.ClientID, e.Username, e.Scopes, e.Audience, expiry, refresh)
+	}
+	return tw.Flush()
+}
+
+// redact returns a placeholder instead of a token. An empty token stays empty.
+func redact(token string) string {
+	if token == "" {
+		return ""
+	}
+	return redacted
+}
@@ -0,0 +1,180 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/cache"
+	"github.com/i-core/tokget/internal/errors"
+)
+
+const testCacheKeyEnv = "TOKGET_TEST_CACHE_KEY"
+
+func TestRunCache(t *testing.T) {
+	os.Setenv(testCacheKeyEnv, "secret")
+	defer os.Unsetenv(testCacheKeyEnv)
+
+	tokens := []string{"secret-access-token", "secret-id-token", "secret-refresh-token"}
+	newCache := func(t *testing.T, dir string) string {
+		filename := filepath.Join(dir, "tokens.json")
+		e := &cache.Entry{
+			Key:          cache.NewKey("https://op", "client", "foo", "openid", ""),
+			AccessToken:  tokens[0],
+			IDToken:      tokens[1],
+			RefreshToken: tokens[2],
+			Expiry:       time.Now().Add(time.Hour),
+		}
+		if err := cache.New(filename, []byte("secret")).Put(e); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	testCases := []struct {
+		name       string
+		args       []string
+		wantOutput string
+		wantTokens bool
+	}{
+		{
+			name:       "list",
+			args:       []string{"list"},
+			wantOutput: "https://op",
+		},
+		{
+			name:       "purge",
+			args:       []string{"purge"},
+			wantOutput: "1 cached tokens are removed",
+		},
+		{
+			name:       "show",
+			args:       []string{"show"},
+			wantOutput: redacted,
+		},
+		{
+			name:       "show with secrets",
+			args:       []string{"show", "--secrets"},
+			wantTokens: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tokget")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := newCache(t, dir)
+
+			var out bytes.Buffer
+			args := append([]string{tc.args[0], "--cache-file", filename, "--cache-key-env", testCacheKeyEnv}, tc.args[1:]...)
+			if err = runCache(&out, args); err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+
+			got := out.String()
+			if tc.wantOutput != "" && !strings.Contains(got, tc.wantOutput) {
+				t.Fatalf("got output:\n%s\nwant it to contain %q", got, tc.wantOutput)
+			}
+			for _, token := range tokens {
+				if printed := strings.Contains(got, token); printed != tc.wantTokens {
+					t.Fatalf("got output:\n%s\nwant token %q printed: %v", got, token, tc.wantTokens)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheOptionsOpen(t *testing.T) {
+	os.Setenv(testCacheKeyEnv, "secret")
+	defer os.Unsetenv(testCacheKeyEnv)
+
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	if err = ioutil.WriteFile(keyFile, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyKeyFile := filepath.Join(dir, "empty-key")
+	if err = ioutil.WriteFile(emptyKeyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		opts    cacheOptions
+		wantErr error
+	}{
+		{
+			name:    "no cache key",
+			wantErr: errors.New(errors.KindCacheKeyInvalid),
+		},
+		{
+			name:    "empty environment variable",
+			opts:    cacheOptions{keyEnv: "TOKGET_TEST_MISSED_CACHE_KEY"},
+			wantErr: errors.New(errors.KindCacheKeyInvalid),
+		},
+		{
+			name:    "empty key file",
+			opts:    cacheOptions{keyFile: emptyKeyFile},
+			wantErr: errors.New(errors.KindCacheKeyInvalid),
+		},
+		{
+			name: "key from environment variable",
+			opts: cacheOptions{keyEnv: testCacheKeyEnv},
+		},
+		{
+			name: "key from file",
+			opts: cacheOptions{keyFile: keyFile},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.filename = filepath.Join(dir, "tokens.json")
+			defer os.Remove(tc.opts.filename)
+
+			c, err := tc.opts.open()
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				if _, err = os.Stat(tc.opts.filename); !os.IsNotExist(err) {
+					t.Fatalf("got cache file %q, want it not to be created", tc.opts.filename)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+
+			e := &cache.Entry{Key: cache.NewKey("https://op", "client", "foo", "openid", ""), AccessToken: "secret-access-token"}
+			if err = c.Put(e); err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			b, err := ioutil.ReadFile(tc.opts.filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(b), e.AccessToken) {
+				t.Fatalf("got cache file:\n%s\nwant the access token encrypted", b)
+			}
+		})
+	}
+}
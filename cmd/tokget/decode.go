@@ -0,0 +1,77 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+// runDecode runs the command decode: it prints the header and claims of a token.
+// The token is taken from the arguments, or from stdin when it is "-" or missed.
+func runDecode(args []string) error {
+	var jwks string
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	fs.StringVar(&jwks, "jwks", "", "a JWK Set file or URL to verify the token's signature")
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		return errors.New("decode accepts one token, got %d", fs.NArg())
+	}
+	raw := fs.Arg(0)
+	if raw == "" || raw == "-" {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "read token from stdin")
+		}
+		raw = string(b)
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return errors.New("token is missed")
+	}
+
+	tok, err := oidc.DecodeToken(context.Background(), raw, jwks)
+	if err != nil {
+		return err
+	}
+	return tok.WriteText(os.Stdout, time.Now())
+}
+
+// writeDecodedTokens writes the decoded access token and ID token of a login. An opaque access token is not decoded.
+func writeDecodedTokens(w io.Writer, data *oidc.LoginData) error {
+	for _, token := range []struct {
+		title string
+		raw   string
+	}{{"ID token", data.IDToken}, {"Access token", data.AccessToken}} {
+		if token.raw == "" {
+			continue
+		}
+		fmt.Fprintf(w, "=== %s ===\n", token.title)
+		tok, err := oidc.DecodeToken(context.Background(), token.raw, "")
+		if errors.KindOf(err) == errors.KindTokenInvalid {
+			fmt.Fprintln(w, "The token is not a JWT.")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = tok.WriteText(w, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
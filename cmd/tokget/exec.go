@@ -0,0 +1,136 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/i-core/tokget/internal/cache"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+// forwardedSignals are signals that the command exec passes through to the child process.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// runExec runs the command exec: it logs a user in (or takes tokens from the token cache),
+// and runs a command with the tokens in the command's environment.
+// The tokens are never printed, so they don't leak into the shell history and the process list.
+//
+// The function returns the exit code of the command (see runCommand).
+func runExec(chromeURL string, args []string) (int, error) {
+	var (
+		cnf          = &oidc.LoginConfig{}
+		opts         = &loginOptions{}
+		cacheOpts    = &cacheOptions{}
+		accessEnv    string
+		idEnv        string
+		verboseLogin bool
+	)
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	loginFlags(fs, cnf, opts)
+	cacheFlags(fs, cacheOpts)
+	fs.StringVar(&accessEnv, "access-token-env", "ACCESS_TOKEN", "an environment variable of the command that contains the access token (empty to skip)")
+	fs.StringVar(&idEnv, "id-token-env", "ID_TOKEN", "an environment variable of the command that contains the ID token (empty to skip)")
+	fs.BoolVar(&verboseLogin, "v", false, "verbose mode")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return 0, errors.New("command is missed: tokget exec [options] -- <command> [arguments]")
+	}
+	if accessEnv != "" && accessEnv == idEnv {
+		return 0, errors.New("access token and ID token are put into the same environment variable %q", accessEnv)
+	}
+	if err := opts.apply(cnf); err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	if verboseLogin {
+		ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+	}
+	var (
+		v   *oidc.LoginData
+		err error
+	)
+	if cacheOpts.enabled {
+		var c *cache.Cache
+		if c, err = cacheOpts.open(); err == nil {
+			v, err = oidc.LoginCached(ctx, chromeURL, cnf, c, cacheOpts.skew)
+		}
+	} else {
+		v, err = oidc.Login(ctx, chromeURL, cnf)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return runCommand(fs.Args(), tokenEnv(os.Environ(), v, accessEnv, idEnv))
+}
+
+// tokenEnv returns an environment with the tokens. The tokens are put into the variables accessEnv and idEnv;
+// an empty name skips the token.
+func tokenEnv(env []string, data *oidc.LoginData, accessEnv, idEnv string) []string {
+	if accessEnv != "" {
+		env = append(env, accessEnv+"="+data.AccessToken)
+	}
+	if idEnv != "" {
+		env = append(env, idEnv+"="+data.IDToken)
+	}
+	return env
+}
+
+// runCommand runs a command with an environment, and passes signals through to it.
+// The first argument is the command's name.
+//
+// The function returns the exit code of the command. When the command is killed by a signal the exit code is 128 + the signal's number.
+func runCommand(args []string, env []string) (int, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// Signals are subscribed before the command is started to not miss a signal that comes in between.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 0, errors.Wrap(err, "start command")
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				// The command can be finished already; the error is not important in this case.
+				cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0, nil
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, errors.Wrap(err, "run command")
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+func TestTokenEnv(t *testing.T) {
+	data := &oidc.LoginData{AccessToken: "at", IDToken: "it"}
+	testCases := []struct {
+		name      string
+		accessEnv string
+		idEnv     string
+		want      []string
+	}{
+		{
+			name:      "default names",
+			accessEnv: "ACCESS_TOKEN",
+			idEnv:     "ID_TOKEN",
+			want:      []string{"HOME=/home/foo", "ACCESS_TOKEN=at", "ID_TOKEN=it"},
+		},
+		{
+			name:      "custom names",
+			accessEnv: "API_TOKEN",
+			idEnv:     "OIDC_ID_TOKEN",
+			want:      []string{"HOME=/home/foo", "API_TOKEN=at", "OIDC_ID_TOKEN=it"},
+		},
+		{
+			name:      "skip ID token",
+			accessEnv: "ACCESS_TOKEN",
+			want:      []string{"HOME=/home/foo", "ACCESS_TOKEN=at"},
+		},
+		{
+			name: "skip all tokens",
+			want: []string{"HOME=/home/foo"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tokenEnv([]string{"HOME=/home/foo"}, data, tc.accessEnv, tc.idEnv)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	env := tokenEnv(os.Environ(), &oidc.LoginData{AccessToken: "at", IDToken: "it"}, "TOKGET_TEST_ACCESS_TOKEN", "TOKGET_TEST_ID_TOKEN")
+
+	testCases := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			args: []string{"sh", "-c", "exit 0"},
+		},
+		{
+			name:     "exit code",
+			args:     []string{"sh", "-c", "exit 3"},
+			wantCode: 3,
+		},
+		{
+			name: "tokens in the environment",
+			args: []string{"sh", "-c", `test "$TOKGET_TEST_ACCESS_TOKEN" = at && test "$TOKGET_TEST_ID_TOKEN" = it`},
+		},
+		{
+			name:     "killed by a signal",
+			args:     []string{"sh", "-c", "kill -KILL $$"},
+			wantCode: 128 + 9,
+		},
+		{
+			name:     "terminated by a signal",
+			args:     []string{"sh", "-c", "kill -TERM $$"},
+			wantCode: 128 + 15,
+		},
+		{
+			name:    "command not found",
+			args:    []string{"tokget-test-unknown-command"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := runCommand(tc.args, env)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("\ngot no errors\nwant an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if code != tc.wantCode {
+				t.Fatalf("got exit code %d, want %d", code, tc.wantCode)
+			}
+		})
+	}
+
+	// The tokens are put into the command's environment only.
+	for _, name := range []string{"TOKGET_TEST_ACCESS_TOKEN", "TOKGET_TEST_ID_TOKEN"} {
+		if v, ok := os.LookupEnv(name); ok {
+			t.Errorf("got %s=%q in tokget's environment, want no variable", name, v)
+		}
+	}
+}
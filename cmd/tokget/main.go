@@ -12,11 +12,15 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
+	"github.com/i-core/tokget/internal/cache"
 	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/kube"
 	"github.com/i-core/tokget/internal/log"
 	"github.com/i-core/tokget/internal/oidc"
+	"github.com/i-core/tokget/internal/output"
 )
 
 // version will be filled at compile time.
@@ -24,34 +28,112 @@ var version = ""
 
 func main() {
 	var (
-		verboseLogin  bool
-		verboseLogout bool
-		scopes        string
+		verboseLogin   bool
+		verboseLogout  bool
+		verboseRefresh bool
+		verboseBench   bool
+		verboseKube    bool
+		verboseInfo    bool
+		verboseIntro   bool
+		verboseRevoke  bool
+		benchFormat    string
+		refreshScopes  string
+		usersFile      string
+		decodeTokens   bool
+		withUserInfo   bool
+		concurrency    int
 	)
 
 	loginCnf := &oidc.LoginConfig{}
+	loginOpts := &loginOptions{}
 	loginCmd := flag.NewFlagSet("login", flag.ExitOnError)
-	loginCmd.StringVar(&loginCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
-	loginCmd.StringVar(&loginCnf.ClientID, "c", "", "an OpenID Connect client ID")
-	loginCmd.StringVar(&loginCnf.RedirectURI, "r", "http://localhost:3000", "an OpenID Connect client's redirect uri")
-	loginCmd.StringVar(&scopes, "s", "openid,profile,email", "OpenID Connect scopes")
-	loginCmd.StringVar(&loginCnf.Username, "u", "", "a user's name")
-	loginCmd.StringVar(&loginCnf.Password, "p", "", "a user's password")
-	loginCmd.BoolVar(&loginCnf.PasswordStdin, "pwd-stdin", false, "a user's password from stdin")
-	loginCmd.StringVar(&loginCnf.UsernameField, "username-field", "input[name=username]", "a CSS selector of the username field on the login form")
-	loginCmd.StringVar(&loginCnf.PasswordField, "password-field", "input[name=password]", "a CSS selector of the password field on the login form")
-	loginCmd.StringVar(&loginCnf.SubmitButton, "submit-button", "button[type=submit]", "a CSS selector of the submit button on the login form")
-	loginCmd.StringVar(&loginCnf.ErrorMessage, "error-message", "p.message", "a CSS selector of an error message on the login form")
+	loginFlags(loginCmd, loginCnf, loginOpts)
+	loginCache := &cacheOptions{}
+	cacheFlags(loginCmd, loginCache)
+	loginOutput := &outputOptions{}
+	outputFlags(loginCmd, loginOutput)
+	loginCmd.StringVar(&usersFile, "users", "", "a file of users for the batch login in CSV or JSON Lines format (one JSON line is printed per user)")
+	loginCmd.IntVar(&concurrency, "concurrency", 1, "a number of users that are logged in concurrently in the batch login")
+	loginCmd.BoolVar(&decodeTokens, "decode", false, "print the decoded ID token and access token to stderr")
+	loginCmd.BoolVar(&withUserInfo, "userinfo", false, "request claims from the UserInfo endpoint with the access token and add them to the output")
 	loginCmd.BoolVar(&verboseLogin, "v", false, "verbose mode")
 
+	userInfoCnf := &oidc.UserInfoConfig{}
+	userInfoCmd := flag.NewFlagSet("userinfo", flag.ExitOnError)
+	userInfoCmd.StringVar(&userInfoCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(userInfoCmd, &userInfoCnf.Metadata)
+	userInfoCmd.StringVar(&userInfoCnf.AccessToken, "t", "", "an access token")
+	userInfoCmd.StringVar(&userInfoCnf.Subject, "sub", "", "an expected subject (the command fails when the response's claim sub does not match it)")
+	userInfoCmd.BoolVar(&verboseInfo, "v", false, "verbose mode")
+
 	logoutCnf := &oidc.LogoutConfig{}
 	logoutCmd := flag.NewFlagSet("logout", flag.ExitOnError)
 	logoutCmd.StringVar(&logoutCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(logoutCmd, &logoutCnf.Metadata)
 	logoutCmd.StringVar(&logoutCnf.IDToken, "t", "", "an ID token")
+	logoutCmd.StringVar(&logoutCnf.ClientID, "c", "", "an OpenID Connect client ID (sent to the end session endpoint, and required to revoke tokens)")
+	logoutCmd.StringVar(&logoutCnf.PostLogoutRedirectURI, "post-logout-redirect-uri", "", "a client's URL to redirect to after the logout (the logout fails when the provider does not redirect to it)")
+	logoutCmd.StringVar(&logoutCnf.LogoutHint, "logout-hint", "", "a hint about the user who is logging out, for example, a user's name")
+	logoutCmd.StringVar(&logoutCnf.UILocales, "ui-locales", "", "preferred languages of the logout pages, space-separated")
+	logoutCmd.StringVar(&logoutCnf.ConfirmButton, "confirm-button", "#kc-logout", "a CSS selector of the confirm button on the logout confirmation page")
+	logoutCmd.BoolVar(&logoutCnf.Revoke, "revoke", false, "revoke the access token and refresh token at the revocation endpoint before the logout")
+	logoutCmd.StringVar(&logoutCnf.AccessToken, "access-token", "", "an access token to revoke")
+	logoutCmd.StringVar(&logoutCnf.RefreshToken, "refresh-token", "", "a refresh token to revoke")
+	clientAuthFlags(logoutCmd, &logoutCnf.ClientAuth)
 	logoutCmd.BoolVar(&verboseLogout, "v", false, "verbose mode")
 
+	introCnf := &oidc.IntrospectConfig{}
+	introCmd := flag.NewFlagSet("introspect", flag.ExitOnError)
+	introCmd.StringVar(&introCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(introCmd, &introCnf.Metadata)
+	introCmd.StringVar(&introCnf.ClientID, "c", "", "an OpenID Connect client ID")
+	clientAuthFlags(introCmd, &introCnf.ClientAuth)
+	introCmd.StringVar(&introCnf.Token, "t", "", "an access token or a refresh token")
+	introCmd.StringVar(&introCnf.TokenTypeHint, "token-type-hint", "", "a type of the token: access_token or refresh_token")
+	introCmd.BoolVar(&verboseIntro, "v", false, "verbose mode")
+
+	revokeCnf := &oidc.RevokeConfig{}
+	revokeCmd := flag.NewFlagSet("revoke", flag.ExitOnError)
+	revokeCmd.StringVar(&revokeCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(revokeCmd, &revokeCnf.Metadata)
+	revokeCmd.StringVar(&revokeCnf.ClientID, "c", "", "an OpenID Connect client ID")
+	clientAuthFlags(revokeCmd, &revokeCnf.ClientAuth)
+	revokeCmd.StringVar(&revokeCnf.Token, "t", "", "an access token or a refresh token")
+	revokeCmd.StringVar(&revokeCnf.TokenTypeHint, "token-type-hint", "", "a type of the token: access_token or refresh_token")
+	revokeCmd.BoolVar(&verboseRevoke, "v", false, "verbose mode")
+
+	refreshCnf := &oidc.RefreshConfig{}
+	refreshCmd := flag.NewFlagSet("refresh", flag.ExitOnError)
+	refreshCmd.StringVar(&refreshCnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(refreshCmd, &refreshCnf.Metadata)
+	refreshCmd.StringVar(&refreshCnf.ClientID, "c", "", "an OpenID Connect client ID")
+	clientAuthFlags(refreshCmd, &refreshCnf.ClientAuth)
+	refreshCmd.StringVar(&refreshCnf.RefreshToken, "refresh-token", "", "a refresh token")
+	refreshCmd.StringVar(&refreshScopes, "s", "", "OpenID Connect scopes (the scopes of the original authentication by default)")
+	refreshOutput := &outputOptions{}
+	outputFlags(refreshCmd, refreshOutput)
+	refreshCmd.BoolVar(&verboseRefresh, "v", false, "verbose mode")
+
+	benchCnf := &oidc.BenchConfig{Login: &oidc.LoginConfig{}}
+	benchOpts := &loginOptions{}
+	benchCmd := flag.NewFlagSet("bench", flag.ExitOnError)
+	loginFlags(benchCmd, benchCnf.Login, benchOpts)
+	benchCmd.DurationVar(&benchCnf.Duration, "duration", 0, "a duration of the load test, for example, 1m (no new logins are started after it)")
+	benchCmd.IntVar(&benchCnf.Iterations, "iterations", 0, "a number of logins (not limited by default)")
+	benchCmd.IntVar(&benchCnf.Concurrency, "concurrency", 1, "a number of concurrent logins")
+	benchCmd.StringVar(&benchFormat, "format", "text", "a format of the summary: text or json")
+	benchCmd.BoolVar(&verboseBench, "v", false, "verbose mode")
+
+	kubeCnf := &oidc.LoginConfig{}
+	kubeOpts := &loginOptions{}
+	kubeCmd := flag.NewFlagSet("kube-credential", flag.ExitOnError)
+	loginFlags(kubeCmd, kubeCnf, kubeOpts)
+	kubeCache := &cacheOptions{}
+	cacheUseFlags(kubeCmd, kubeCache)
+	kubeCmd.BoolVar(&verboseKube, "v", false, "verbose mode")
+
 	flag.Usage = func() {
-		fmt.Fprintln(os.Stderr, usage)
+		fmt.Fprint(os.Stderr, usage+"\n")
 	}
 
 	if len(os.Args) == 1 {
@@ -79,25 +161,96 @@ func main() {
 		case loginCmd.Name():
 			loginCmd.Parse(args[1:])
 
-			loginCnf.Scopes = strings.ReplaceAll(scopes, ",", " ")
+			if err := loginOpts.apply(loginCnf); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			if err := loginOutput.init(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
 
 			ctx := context.Background()
 			if verboseLogin {
 				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
 			}
-			v, err := oidc.Login(ctx, chromeURL, loginCnf)
+
+			if usersFile != "" {
+				// The batch login prints a JSON line per user, so other outputs are not supported.
+				if loginOutput.format != output.FormatJSON || loginOutput.filename != "" || decodeTokens || withUserInfo {
+					fmt.Fprintln(os.Stderr, "Error: the batch login prints JSON lines only, options --output, --out-file, --decode and --userinfo are not supported")
+					os.Exit(1)
+				}
+				// The batch login always logs users in to get fresh tokens for each user.
+				if loginCache.enabled {
+					fmt.Fprintln(os.Stderr, "Error: the batch login does not use the token cache, option --cache is not supported")
+					os.Exit(1)
+				}
+				users, err := oidc.LoadUsers(usersFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					os.Exit(1)
+				}
+				enc := json.NewEncoder(os.Stdout)
+				failed, err := oidc.LoginBatch(ctx, chromeURL, loginCnf, users, concurrency, func(res *oidc.BatchResult) {
+					if err := enc.Encode(res); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: encode user data to JSON: %s\n", err)
+					}
+				})
+				if err != nil {
+					if errors.Cause(err) != context.Canceled {
+						fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					}
+					os.Exit(1)
+				}
+				if failed > 0 {
+					fmt.Fprintf(os.Stderr, "Error: %d of %d users failed to log in\n", failed, len(users))
+					os.Exit(1)
+				}
+				os.Exit(0)
+			}
+
+			var (
+				v   *oidc.LoginData
+				err error
+			)
+			if loginCache.enabled {
+				var c *cache.Cache
+				if c, err = loginCache.open(); err == nil {
+					v, err = oidc.LoginCached(ctx, chromeURL, loginCnf, c, loginCache.skew)
+				}
+			} else {
+				v, err = oidc.Login(ctx, chromeURL, loginCnf)
+			}
 			if err != nil {
 				if errors.Cause(err) != context.Canceled {
 					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				}
 				os.Exit(1)
 			}
-			b, err := json.Marshal(v)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: encode user data to JSON: %s\n", err)
+			if withUserInfo {
+				userInfo := &oidc.UserInfoConfig{Endpoint: loginCnf.Endpoint, Metadata: loginCnf.Metadata, AccessToken: v.AccessToken}
+				// The UserInfo response must be about the user of the ID token.
+				if userInfo.Subject, err = idTokenSubject(ctx, v.IDToken); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					os.Exit(1)
+				}
+				if v.UserInfo, err = oidc.UserInfo(ctx, userInfo); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					os.Exit(1)
+				}
+			}
+			if decodeTokens {
+				// The decoded tokens are written to stderr to keep stdout for the output format.
+				if err = writeDecodedTokens(os.Stderr, v); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+					os.Exit(1)
+				}
+			}
+			if err = loginOutput.print(v); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 				os.Exit(1)
 			}
-			fmt.Fprintln(flag.CommandLine.Output(), string(b))
 			os.Exit(0)
 		case logoutCmd.Name():
 			logoutCmd.Parse(args[1:])
@@ -114,6 +267,184 @@ func main() {
 				os.Exit(1)
 			}
 			os.Exit(0)
+		case userInfoCmd.Name():
+			userInfoCmd.Parse(args[1:])
+
+			ctx := context.Background()
+			if verboseInfo {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			claims, err := oidc.UserInfo(ctx, userInfoCnf)
+			if err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			b, err := json.Marshal(claims)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: encode claims to JSON: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(os.Stdout, string(b))
+			os.Exit(0)
+		case introCmd.Name():
+			introCmd.Parse(args[1:])
+
+			ctx := context.Background()
+			if verboseIntro {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			resp, err := oidc.Introspect(ctx, introCnf)
+			if err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			b, err := json.Marshal(resp)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: encode introspection response to JSON: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintln(os.Stdout, string(b))
+			os.Exit(0)
+		case revokeCmd.Name():
+			revokeCmd.Parse(args[1:])
+
+			ctx := context.Background()
+			if verboseRevoke {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			if err := oidc.Revoke(ctx, revokeCnf); err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case refreshCmd.Name():
+			refreshCmd.Parse(args[1:])
+
+			refreshCnf.Scopes = strings.ReplaceAll(refreshScopes, ",", " ")
+			if err := refreshOutput.init(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+
+			ctx := context.Background()
+			if verboseRefresh {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			v, err := oidc.Refresh(ctx, refreshCnf)
+			if err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			if err = refreshOutput.print(v); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case benchCmd.Name():
+			benchCmd.Parse(args[1:])
+
+			if benchFormat != "text" && benchFormat != "json" {
+				fmt.Fprintf(os.Stderr, "Error: summary format %q is not supported\n", benchFormat)
+				os.Exit(1)
+			}
+			if err := benchOpts.apply(benchCnf.Login); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if verboseBench {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			// The interruption stops the load test, and the summary of finished logins is printed.
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			go func() {
+				<-interrupt
+				cancel()
+			}()
+
+			report, err := oidc.Bench(ctx, chromeURL, benchCnf)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			if benchFormat == "json" {
+				err = json.NewEncoder(os.Stdout).Encode(report)
+			} else {
+				err = report.WriteText(os.Stdout)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: write summary: %s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case kubeCmd.Name():
+			kubeCmd.Parse(args[1:])
+
+			if err := kubeOpts.apply(kubeCnf); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+
+			ctx := context.Background()
+			if verboseKube {
+				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+			}
+			c, err := kubeCache.open()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			v, err := oidc.LoginCached(ctx, chromeURL, kubeCnf, c, kubeCache.skew)
+			if err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			cred, err := kube.NewExecCredential(v.IDToken)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			b, err := json.Marshal(cred)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: encode credential to JSON: %s\n", err)
+				os.Exit(1)
+			}
+			// kubectl reads the credential from stdout.
+			fmt.Fprintln(os.Stdout, string(b))
+			os.Exit(0)
+		case "exec":
+			code, err := runExec(chromeURL, args[1:])
+			if err != nil {
+				if errors.Cause(err) != context.Canceled {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+			os.Exit(code)
+		case "decode":
+			if err := runDecode(args[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case "cache":
+			if err := runCache(os.Stdout, args[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
 		default:
 			fmt.Fprintf(os.Stderr, "%q is not valid command.\n", arg)
 			os.Exit(1)
@@ -124,6 +455,162 @@ func main() {
 	os.Exit(1)
 }
 
+// loginOptions are login flags that are converted before they are put into a login configuration.
+type loginOptions struct {
+	scopes         string
+	grantScopes    string
+	scriptFile     string
+	expectToken    string
+	expectClaims   stringsFlag
+	expectContains stringsFlag
+	expectScopes   stringsFlag
+}
+
+// loginFlags defines flags of the login process.
+func loginFlags(fs *flag.FlagSet, cnf *oidc.LoginConfig, opts *loginOptions) {
+	fs.StringVar(&cnf.Endpoint, "e", "", "an OpenID Connect endpoint")
+	metadataFlags(fs, &cnf.Metadata)
+	fs.StringVar(&cnf.Engine, "engine", oidc.EngineChrome, "a login engine: chrome or http (plain HTML forms without Chrome)")
+	fs.StringVar(&cnf.Flow, "flow", oidc.FlowImplicit, "an OAuth2 flow: implicit or code (authorization code flow with PKCE)")
+	fs.StringVar(&cnf.ClientID, "c", "", "an OpenID Connect client ID")
+	clientAuthFlags(fs, &cnf.ClientAuth)
+	fs.StringVar(&cnf.RedirectURI, "r", "http://localhost:3000", "an OpenID Connect client's redirect uri")
+	fs.StringVar(&opts.scopes, "s", "openid,profile,email", "OpenID Connect scopes")
+	fs.StringVar(&cnf.Audience, "audience", "", "an audience of the access token (the parameter audience of the authentication request)")
+	fs.StringVar(&cnf.Username, "u", "", "a user's name")
+	fs.StringVar(&cnf.Password, "p", "", "a user's password")
+	fs.BoolVar(&cnf.PasswordStdin, "pwd-stdin", false, "a user's password from stdin")
+	fs.StringVar(&cnf.UsernameField, "username-field", "input[name=username]", "a CSS selector of the username field on the login form")
+	fs.StringVar(&cnf.PasswordField, "password-field", "input[name=password]", "a CSS selector of the password field on the login form")
+	fs.StringVar(&cnf.SubmitButton, "submit-button", "button[type=submit]", "a CSS selector of the submit button on the login form")
+	fs.StringVar(&cnf.UsernameSubmit, "username-submit", "", "a CSS selector of the submit button on the username page of the multi-step login (the password is filled on the next page)")
+	fs.StringVar(&opts.scriptFile, "script", "", "a login script file in YAML or JSON format (fills the username and password by default)")
+	fs.StringVar(&cnf.ErrorMessage, "error-message", "p.message", "a CSS selector of an error message on the login form")
+	fs.StringVar(&cnf.TOTP.Secret, "totp-secret", "", "a TOTP shared secret in base32 format for the second factor")
+	fs.StringVar(&cnf.TOTP.SecretFile, "totp-secret-file", "", "a TOTP shared secret from a file")
+	fs.StringVar(&cnf.TOTP.SecretEnv, "totp-secret-env", "", "a TOTP shared secret from an environment variable")
+	fs.StringVar(&cnf.TOTP.Field, "otp-field", "input[name=otp]", "a CSS selector of the one-time password field")
+	fs.StringVar(&cnf.TOTP.Submit, "otp-submit", "[type=submit]", "a CSS selector of the submit button on the one-time password page")
+	fs.StringVar(&cnf.WebAuthn.CredentialFile, "webauthn-credential", "", "a file that contains a stored WebAuthn credential for the passwordless login by a passkey")
+	fs.StringVar(&cnf.WebAuthn.Button, "webauthn-button", "", "a CSS selector of the button that starts the passkey ceremony (the submit button by default)")
+	fs.StringVar(&cnf.ConsentScope, "consent-scope", "input[name=grant_scope]", "a CSS selector of the scope checkboxes on the consent page")
+	fs.StringVar(&cnf.ConsentApprove, "consent-approve", "#accept", "a CSS selector of the approve button on the consent page")
+	fs.StringVar(&cnf.ConsentDeny, "consent-deny", "#reject", "a CSS selector of the deny button on the consent page")
+	fs.StringVar(&opts.grantScopes, "grant-scopes", "", "scopes to grant on the consent page (all offered scopes by default)")
+	fs.BoolVar(&cnf.DenyConsent, "deny-consent", false, "deny the consent instead of approving it")
+	fs.BoolVar(&cnf.Verify, "verify", false, "verify the ID token's signature and claims with the OpenID Connect Provider's JWK Set")
+	fs.StringVar(&opts.expectToken, "expect-token", oidc.TokenID, "a token which claims are checked by --expect-claim and --expect-claim-contains without a token prefix: id or access")
+	fs.Var(&opts.expectClaims, "expect-claim", "fail the login when the token's claim does not equal to a value, in format [id:|access:]path=value (repeatable; nested claims are separated by dots)")
+	fs.Var(&opts.expectContains, "expect-claim-contains", "fail the login when the token's claim does not contain a value, in format [id:|access:]path=value (repeatable)")
+	fs.Var(&opts.expectScopes, "expect-scope", "fail the login when the access token's scope, or the granted scope for an opaque access token, does not contain a scope (repeatable)")
+}
+
+// apply puts the options into a login configuration.
+func (opts *loginOptions) apply(cnf *oidc.LoginConfig) error {
+	cnf.Scopes = strings.ReplaceAll(opts.scopes, ",", " ")
+	cnf.GrantScopes = strings.ReplaceAll(opts.grantScopes, ",", " ")
+	if opts.scriptFile != "" {
+		script, err := oidc.LoadScript(opts.scriptFile)
+		if err != nil {
+			return err
+		}
+		cnf.Script = script
+	}
+
+	if opts.expectToken != oidc.TokenID && opts.expectToken != oidc.TokenAccess {
+		return errors.New("token %q of claim checks is not valid, want id or access", opts.expectToken)
+	}
+	cnf.Expect = nil
+	for _, list := range []struct {
+		op     string
+		values []string
+	}{{oidc.ExpectEquals, opts.expectClaims}, {oidc.ExpectContains, opts.expectContains}} {
+		for _, v := range list.values {
+			check, err := parseClaimCheck(v, opts.expectToken)
+			if err != nil {
+				return err
+			}
+			check.Op = list.op
+			cnf.Expect = append(cnf.Expect, check)
+		}
+	}
+	for _, v := range opts.expectScopes {
+		cnf.Expect = append(cnf.Expect, &oidc.ClaimCheck{Token: oidc.TokenAccess, Op: oidc.ExpectScope, Value: v})
+	}
+	return nil
+}
+
+// idTokenSubject returns the claim "sub" of an ID token to check the subject of the UserInfo response.
+// When there is no ID token the function returns an empty subject, and the check is skipped.
+func idTokenSubject(ctx context.Context, idToken string) (string, error) {
+	if idToken == "" {
+		log.DebuggerFromContext(ctx).Debugln("There is no ID token, the subject of the UserInfo response is not checked")
+		return "", nil
+	}
+	tok, err := oidc.DecodeToken(ctx, idToken, "")
+	if err != nil {
+		return "", errors.New(errors.KindIDTokenInvalid, err, "get subject of ID token")
+	}
+	sub, _ := tok.Claims["sub"].(string)
+	if sub == "" {
+		return "", errors.New(errors.KindIDTokenInvalid, "ID token does not contain the claim \"sub\"")
+	}
+	return sub, nil
+}
+
+// parseClaimCheck parses a claim check in format [token:]path=value. The token is id or access;
+// a check without the token prefix checks the default token.
+func parseClaimCheck(v, defaultToken string) (*oidc.ClaimCheck, error) {
+	check := &oidc.ClaimCheck{Token: defaultToken}
+	// A claim's path can contain colons (for example, https://example.com/roles), so only the known tokens are prefixes.
+	for _, token := range []string{oidc.TokenID, oidc.TokenAccess} {
+		if strings.HasPrefix(v, token+":") {
+			check.Token, v = token, v[len(token)+1:]
+			break
+		}
+	}
+	i := strings.Index(v, "=")
+	if i <= 0 {
+		return nil, errors.New("claim check %q is not valid, want [id:|access:]path=value", v)
+	}
+	check.Path, check.Value = v[:i], v[i+1:]
+	return check, nil
+}
+
+// stringsFlag is a flag that can be repeated. Each occurrence adds a value.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// metadataFlags defines flags that override endpoints from OpenID Connect Provider's discovery document.
+func metadataFlags(fs *flag.FlagSet, meta *oidc.ProviderMetadata) {
+	fs.StringVar(&meta.AuthorizationEndpoint, "auth-endpoint", "", "an OpenID Connect Provider's authorization endpoint (overrides discovery)")
+	fs.StringVar(&meta.TokenEndpoint, "token-endpoint", "", "an OpenID Connect Provider's token endpoint (overrides discovery)")
+	fs.StringVar(&meta.UserinfoEndpoint, "userinfo-endpoint", "", "an OpenID Connect Provider's userinfo endpoint (overrides discovery)")
+	fs.StringVar(&meta.JWKSURI, "jwks-uri", "", "an OpenID Connect Provider's JWK Set URL (overrides discovery)")
+	fs.StringVar(&meta.EndSessionEndpoint, "end-session-endpoint", "", "an OpenID Connect Provider's end session endpoint (overrides discovery)")
+	fs.StringVar(&meta.IntrospectionEndpoint, "introspection-endpoint", "", "an OAuth2 token introspection endpoint (overrides discovery)")
+	fs.StringVar(&meta.RevocationEndpoint, "revocation-endpoint", "", "an OAuth2 token revocation endpoint (overrides discovery)")
+}
+
+// clientAuthFlags defines flags of the client authentication at the token endpoint.
+func clientAuthFlags(fs *flag.FlagSet, auth *oidc.ClientAuth) {
+	fs.StringVar(&auth.Method, "client-auth", "", "a client authentication method: none, client_secret_basic, client_secret_post, client_secret_jwt, private_key_jwt (chosen by credentials by default)")
+	fs.StringVar(&auth.Secret, "client-secret", "", "a client's secret")
+	fs.StringVar(&auth.SecretFile, "client-secret-file", "", "a client's secret from a file")
+	fs.StringVar(&auth.SecretEnv, "client-secret-env", "", "a client's secret from an environment variable")
+	fs.StringVar(&auth.KeyFile, "client-key-file", "", "a client's private key file in PEM or JWK format (private_key_jwt)")
+	fs.StringVar(&auth.KeyID, "client-key-id", "", "a client's key ID (private_key_jwt)")
+	fs.StringVar(&auth.AssertionAlg, "client-assertion-alg", "", "a JWS algorithm of a client assertion (client_secret_jwt, private_key_jwt)")
+}
+
 const usage = `
 usage: tokget [options] <command> [options]
 
@@ -133,6 +620,18 @@ Options:
 Commands:
  login   Logs a user in and returns its access token and ID token.
  logout  Logs a user out.
+ userinfo
+         Requests claims about a user from the UserInfo endpoint with an access token.
+ introspect
+         Requests the state of an access token or a refresh token from the introspection endpoint.
+ revoke  Revokes an access token or a refresh token.
+ refresh Exchanges a refresh token to new tokens.
+ bench   Runs the login process repeatedly and reports latencies of its steps.
+ exec    Logs a user in and runs a command with the tokens in its environment (tokget exec [options] -- <command>).
+ decode  Prints the header and claims of a JWT (tokget decode [options] [token|-]).
+ cache   Lists, shows or purges cached tokens (tokget cache list|show|purge).
+ kube-credential
+         Prints a kubectl ExecCredential with a cached or new ID token.
  version Prints version of the tool.
  help    Prints help about the tool.
 `
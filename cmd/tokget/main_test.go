@@ -0,0 +1,138 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+func TestParseClaimCheck(t *testing.T) {
+	testCases := []struct {
+		name    string
+		v       string
+		want    *oidc.ClaimCheck
+		wantErr bool
+	}{
+		{
+			name: "default token",
+			v:    "sub=foo",
+			want: &oidc.ClaimCheck{Token: oidc.TokenID, Path: "sub", Value: "foo"},
+		},
+		{
+			name: "access token",
+			v:    "access:aud=api",
+			want: &oidc.ClaimCheck{Token: oidc.TokenAccess, Path: "aud", Value: "api"},
+		},
+		{
+			name: "ID token",
+			v:    "id:groups=admin",
+			want: &oidc.ClaimCheck{Token: oidc.TokenID, Path: "groups", Value: "admin"},
+		},
+		{
+			name: "path with colons",
+			v:    "https://example.com/roles=auditor",
+			want: &oidc.ClaimCheck{Token: oidc.TokenID, Path: "https://example.com/roles", Value: "auditor"},
+		},
+		{
+			name: "access token and path with colons",
+			v:    "access:https://example.com/roles=auditor",
+			want: &oidc.ClaimCheck{Token: oidc.TokenAccess, Path: "https://example.com/roles", Value: "auditor"},
+		},
+		{
+			name: "value with equal sign",
+			v:    "access:scope=a=b",
+			want: &oidc.ClaimCheck{Token: oidc.TokenAccess, Path: "scope", Value: "a=b"},
+		},
+		{
+			name:    "no value",
+			v:       "access:sub",
+			wantErr: true,
+		},
+		{
+			name:    "empty path",
+			v:       "access:=foo",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseClaimCheck(tc.v, oidc.TokenID)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("\ngot no errors\nwant an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIDTokenSubject(t *testing.T) {
+	unsignedJWT := func(claims string) string {
+		enc := base64.RawURLEncoding.EncodeToString
+		return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + "."
+	}
+
+	testCases := []struct {
+		name    string
+		idToken string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "subject",
+			idToken: unsignedJWT(`{"sub":"foo"}`),
+			want:    "foo",
+		},
+		{
+			name: "no ID token",
+		},
+		{
+			name:    "invalid ID token",
+			idToken: "not-a-jwt",
+			wantErr: errors.New(errors.KindIDTokenInvalid),
+		},
+		{
+			name:    "no subject",
+			idToken: unsignedJWT(`{"iss":"https://op"}`),
+			wantErr: errors.New(errors.KindIDTokenInvalid),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := idTokenSubject(context.Background(), tc.idToken)
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if got != tc.want {
+				t.Errorf("got subject %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+
+	"github.com/i-core/tokget/internal/atomicfile"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/oidc"
+	"github.com/i-core/tokget/internal/output"
+)
+
+// outputOptions are flags of the output of tokens.
+type outputOptions struct {
+	format   string
+	template string
+	filename string
+	printer  *output.Printer
+}
+
+// outputFlags defines flags of the output of tokens.
+func outputFlags(fs *flag.FlagSet, opts *outputOptions) {
+	fs.StringVar(&opts.format, "output", output.FormatJSON, "an output format: json, env, dotenv, raw-access, raw-id, header or template")
+	fs.StringVar(&opts.template, "template", "", "a Go template of the output format template, for example, {{.AccessToken}}")
+	fs.StringVar(&opts.filename, "out-file", "", "a file to write the output to instead of stdout (it is replaced atomically and readable by the owner only)")
+}
+
+// init validates the output format before tokens are requested.
+func (opts *outputOptions) init() error {
+	p, err := output.New(opts.format, opts.template)
+	if err != nil {
+		return err
+	}
+	opts.printer = p
+	return nil
+}
+
+// print writes tokens to stdout or the output file.
+func (opts *outputOptions) print(data *oidc.LoginData) error {
+	if opts.filename == "" {
+		return opts.printer.Print(os.Stdout, data)
+	}
+	var buf bytes.Buffer
+	if err := opts.printer.Print(&buf, data); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(opts.filename, buf.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "write output file")
+	}
+	return nil
+}
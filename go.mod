@@ -3,7 +3,11 @@ module github.com/i-core/tokget
 go 1.12
 
 require (
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/chromedp/cdproto v0.0.0-20190429085128-1aa4f57ff2a9
 	github.com/chromedp/chromedp v0.3.0
+	github.com/mailru/easyjson v0.0.0-20190403194419-1ea4449da983
 	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
+	golang.org/x/net v0.0.0-20190404232315-eb5bcb51f2a3
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,47 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package atomicfile replaces files atomically, so a reader never sees a partially written file,
+// and a crash or a full disk does not destroy the previous content of the file.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+// WriteFile writes data to a file atomically: data is written to a temporary file in the same directory,
+// and then the temporary file is renamed to the file. The file gets the permissions perm
+// even if it already exists.
+func WriteFile(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"-")
+	if err != nil {
+		return errors.Wrap(err, "create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "set file permissions")
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "write temporary file")
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "write temporary file")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "write temporary file")
+	}
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		return errors.Wrap(err, "replace file")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "tokens.env")
+
+	for _, content := range []string{"ACCESS_TOKEN=old\n", "ACCESS_TOKEN=new\n"} {
+		if err = WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+		}
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("got file content %q, want %q", b, content)
+		}
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got file permissions %o, want 0600", perm)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files in the directory, want 1 (temporary files must be removed)", len(files))
+	}
+}
+
+func TestWriteFileMissedDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = WriteFile(filepath.Join(dir, "missed", "tokens.env"), []byte("ACCESS_TOKEN=foo\n"), 0600); err == nil {
+		t.Fatal("got no errors, want an error")
+	}
+}
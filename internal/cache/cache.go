@@ -0,0 +1,307 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package cache stores tokens on disk to reuse them between runs of the tool.
+//
+// The cache file can be encrypted by AES-256-GCM with a key that is derived from a secret by scrypt.
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/i-core/tokget/internal/atomicfile"
+	"github.com/i-core/tokget/internal/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// fileMode is the permissions of the cache file. The file contains tokens so only its owner can read it.
+const fileMode = 0600
+
+// Parameters of the key derivation by scrypt.
+const (
+	kdfScrypt = "scrypt"
+	scryptN   = 1 << 15
+	scryptR   = 8
+	scryptP   = 1
+	keyLen    = 32
+	saltLen   = 16
+)
+
+// additionalData binds the ciphertext to the format of the cache file.
+var additionalData = []byte("tokget-cache-v1")
+
+// Key identifies tokens in the cache.
+type Key struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	Username string `json:"username"`
+	Scopes   string `json:"scopes"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// NewKey returns a key of tokens. Scopes are normalized, so the order of scopes does not matter.
+func NewKey(issuer, clientID, username, scopes, audience string) Key {
+	fields := strings.Fields(scopes)
+	sort.Strings(fields)
+	return Key{
+		Issuer:   strings.TrimSuffix(issuer, "/"),
+		ClientID: clientID,
+		Username: username,
+		Scopes:   strings.Join(fields, " "),
+		Audience: audience,
+	}
+}
+
+// Entry is tokens that are stored in the cache.
+type Entry struct {
+	Key
+	AccessToken  string    `json:"access_token"`
+	IDToken      string    `json:"id_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Scope        string    `json:"scope,omitempty"` // scopes of the access token that the provider returns
+	Expiry       time.Time `json:"expiry"`          // a time when the earliest of the tokens expires; zero when it is unknown
+	Updated      time.Time `json:"updated"`         // a time when the tokens are stored
+
+	AccessTokenExpiry time.Time `json:"access_token_expiry"` // a time when the access token expires by expires_in; zero when it is unknown
+}
+
+// Valid returns true when the tokens do not expire within a skew after a time.
+func (e *Entry) Valid(now time.Time, skew time.Duration) bool {
+	return !e.Expiry.IsZero() && now.Add(skew).Before(e.Expiry)
+}
+
+// file is the content of the cache file. An encrypted file contains only the encrypted entries.
+type file struct {
+	Entries   []*Entry `json:"entries,omitempty"`
+	Encrypted *sealed  `json:"encrypted,omitempty"`
+}
+
+// sealed is entries that are encrypted by AES-256-GCM.
+type sealed struct {
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Cache is an on-disk cache of tokens.
+//
+// Each operation reads the cache file, and each change rewrites it. The file is replaced atomically,
+// so a concurrent reader never sees a partially written file, but concurrent changes can overwrite each other.
+type Cache struct {
+	filename string
+	secret   []byte
+
+	mu   sync.Mutex
+	salt []byte // a salt of the derived key
+	key  []byte // a key that is derived from the secret
+}
+
+// New returns a cache that is stored in a file. The file is created on the first change.
+//
+// When the secret is not empty the cache file is encrypted by a key that is derived from the secret.
+// A plain cache file is encrypted on the first change.
+func New(filename string, secret []byte) *Cache {
+	return &Cache{filename: filename, secret: secret}
+}
+
+// DefaultFilename returns the default path of the cache file in the user's cache directory.
+func DefaultFilename() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "tokget", "tokens.json")
+}
+
+// Get returns tokens by a key. If the cache does not contain the tokens the function returns nil.
+func (c *Cache) Get(key Key) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Key == key {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// Entries returns all tokens in the cache.
+func (c *Cache) Entries() ([]*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load()
+}
+
+// Put stores tokens. The tokens replace the tokens with the same key.
+func (c *Cache) Put(entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		return err
+	}
+	updated := []*Entry{entry}
+	for _, e := range entries {
+		if e.Key != entry.Key {
+			updated = append(updated, e)
+		}
+	}
+	return c.save(updated)
+}
+
+// Delete removes tokens that match a function, and returns the number of removed tokens.
+func (c *Cache) Delete(match func(*Entry) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries, err := c.load()
+	if err != nil {
+		return 0, err
+	}
+	var kept []*Entry
+	for _, e := range entries {
+		if !match(e) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(entries) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, c.save(kept)
+}
+
+// load reads the cache file, and decrypts it when it is encrypted. A missed file is an empty cache.
+func (c *Cache) load() ([]*Entry, error) {
+	b, err := ioutil.ReadFile(c.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.KindCacheInvalid, err, "read token cache")
+	}
+	f := &file{}
+	if err = json.Unmarshal(b, f); err != nil {
+		return nil, errors.New(errors.KindCacheInvalid, err, "parse token cache %q", c.filename)
+	}
+	if f.Encrypted == nil {
+		return f.Entries, nil
+	}
+
+	s := f.Encrypted
+	if len(c.secret) == 0 {
+		return nil, errors.New(errors.KindCacheKeyInvalid, "token cache %q is encrypted but the cache key is missed", c.filename)
+	}
+	if s.KDF != kdfScrypt {
+		return nil, errors.New(errors.KindCacheInvalid, "token cache %q has unknown key derivation function %q", c.filename, s.KDF)
+	}
+	if err = c.deriveKey(s.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(c.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, errors.New(errors.KindCacheInvalid, "token cache %q has an invalid nonce", c.filename)
+	}
+	plaintext, err := aead.Open(nil, s.Nonce, s.Ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New(errors.KindCacheKeyInvalid, "decrypt token cache %q: the cache key is wrong or the file is corrupted", c.filename)
+	}
+	var entries []*Entry
+	if err = json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, errors.New(errors.KindCacheInvalid, err, "parse decrypted token cache %q", c.filename)
+	}
+	return entries, nil
+}
+
+// save writes the cache file atomically.
+func (c *Cache) save(entries []*Entry) error {
+	f := &file{Entries: entries}
+	if len(c.secret) != 0 {
+		plaintext, err := json.Marshal(entries)
+		if err != nil {
+			return errors.Wrap(err, "encode token cache")
+		}
+		if c.key == nil {
+			salt := make([]byte, saltLen)
+			if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+				return errors.Wrap(err, "generate salt")
+			}
+			if err = c.deriveKey(salt); err != nil {
+				return err
+			}
+		}
+		aead, err := newAEAD(c.key)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return errors.Wrap(err, "generate nonce")
+		}
+		f = &file{Encrypted: &sealed{
+			KDF:        kdfScrypt,
+			Salt:       c.salt,
+			Nonce:      nonce,
+			Ciphertext: aead.Seal(nil, nonce, plaintext, additionalData),
+		}}
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode token cache")
+	}
+	if err = os.MkdirAll(filepath.Dir(c.filename), 0700); err != nil {
+		return errors.Wrap(err, "create token cache directory")
+	}
+	if err = atomicfile.WriteFile(c.filename, b, fileMode); err != nil {
+		return errors.Wrap(err, "write token cache")
+	}
+	return nil
+}
+
+// deriveKey derives a key from the secret with a salt. The key is derived once for a salt
+// because of scrypt is slow by design.
+func (c *Cache) deriveKey(salt []byte) error {
+	if c.key != nil && string(c.salt) == string(salt) {
+		return nil
+	}
+	key, err := scrypt.Key(c.secret, salt, scryptN, scryptR, scryptP, keyLen)
+	if err != nil {
+		return errors.Wrap(err, "derive cache key")
+	}
+	c.salt, c.key = salt, key
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher")
+	}
+	return aead, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cache", "tokens.json")
+	c := New(filename, nil)
+
+	key := NewKey("https://op/", "client", "foo", "openid email", "")
+	got, err := c.Get(key)
+	if err != nil || got != nil {
+		t.Fatalf("got %+v (%v) from an empty cache, want nothing", got, err)
+	}
+
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	foo := &Entry{Key: key, AccessToken: "at-1", IDToken: "it-1", Expiry: now.Add(time.Hour), Updated: now}
+	bar := &Entry{Key: NewKey("https://op", "client", "bar", "openid", ""), AccessToken: "at-2", IDToken: "it-2"}
+	for _, e := range []*Entry{foo, bar} {
+		if err = c.Put(e); err != nil {
+			t.Fatalf("failed to put tokens: %s", err)
+		}
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("got cache file permissions %o, want 0600", perm)
+	}
+
+	// The order of scopes and the trailing slash of the issuer do not matter.
+	got, err = c.Get(NewKey("https://op", "client", "foo", "email openid", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, foo) {
+		t.Fatalf("got %+v, want %+v", got, foo)
+	}
+	for _, k := range []Key{
+		NewKey("https://op", "client", "foo", "openid", ""),
+		NewKey("https://op", "client", "foo", "openid email", "api"),
+		NewKey("https://op", "another-client", "foo", "openid email", ""),
+	} {
+		if got, err = c.Get(k); err != nil || got != nil {
+			t.Errorf("got %+v (%v) by the key %+v, want nothing", got, err, k)
+		}
+	}
+
+	// New tokens replace the tokens with the same key.
+	foo2 := &Entry{Key: key, AccessToken: "at-3", IDToken: "it-3"}
+	if err = c.Put(foo2); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = c.Get(key); err != nil || got.AccessToken != "at-3" {
+		t.Fatalf("got %+v (%v), want %+v", got, err, foo2)
+	}
+	if got, err = c.Get(bar.Key); err != nil || got.AccessToken != "at-2" {
+		t.Fatalf("got %+v (%v), want %+v", got, err, bar)
+	}
+
+	if err = ioutil.WriteFile(filename, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wantErr := errors.New(errors.KindCacheInvalid)
+	if _, err = c.Get(key); !errors.Match(err, wantErr) {
+		t.Fatalf("\ngot error:\n\t%v\nwant error:\n\t%s", err, wantErr)
+	}
+}
+
+func TestEntryValid(t *testing.T) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name   string
+		expiry time.Time
+		want   bool
+	}{
+		{name: "unknown expiry", want: false},
+		{name: "expired", expiry: now.Add(-time.Second), want: false},
+		{name: "expires within skew", expiry: now.Add(30 * time.Second), want: false},
+		{name: "valid", expiry: now.Add(2 * time.Minute), want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &Entry{Expiry: tc.expiry}
+			if got := e.Valid(now, time.Minute); got != tc.want {
+				t.Fatalf("got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCacheEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "tokens.json")
+
+	// A plain cache is encrypted on the first change.
+	key := NewKey("https://op", "client", "foo", "openid", "")
+	if err = New(filename, nil).Put(&Entry{Key: key, AccessToken: "plain-at"}); err != nil {
+		t.Fatal(err)
+	}
+	c := New(filename, []byte("secret"))
+	if err = c.Put(&Entry{Key: NewKey("https://op", "client", "bar", "openid", ""), AccessToken: "secret-at", RefreshToken: "secret-rt"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"plain-at", "secret-at", "secret-rt", "foo", "bar"} {
+		if bytes.Contains(b, []byte(s)) {
+			t.Errorf("the encrypted cache file contains %q:\n%s", s, b)
+		}
+	}
+
+	entries, err := New(filename, []byte("secret")).Entries()
+	if err != nil {
+		t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.AccessToken)
+	}
+	if want := []string{"secret-at", "plain-at"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got access tokens %q, want %q", got, want)
+	}
+
+	// The key is derived once, and the changed file is still readable.
+	if _, err = c.Delete(func(e *Entry) bool { return e.Username == "foo" }); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = New(filename, []byte("secret")).Entries(); err != nil || len(entries) != 1 {
+		t.Fatalf("got %d entries (%v), want 1", len(entries), err)
+	}
+
+	wantErr := errors.New(errors.KindCacheKeyInvalid)
+	for _, secret := range [][]byte{nil, []byte("wrong")} {
+		if _, err = New(filename, secret).Entries(); !errors.Match(err, wantErr) {
+			t.Errorf("\ngot error:\n\t%v\nwant error:\n\t%s", err, wantErr)
+		}
+	}
+}
@@ -38,6 +38,8 @@ const (
 	DomainNetwork Domain = "network"
 	// DomainRuntime is the domain "Runtime". See https://chromedevtools.github.io/devtools-protocol/tot/Runtime.
 	DomainRuntime Domain = "runtime"
+	// DomainWebAuthn is the domain "WebAuthn". See https://chromedevtools.github.io/devtools-protocol/tot/WebAuthn.
+	DomainWebAuthn Domain = "webauthn"
 )
 
 // ConnectWithContext establishes a connection with a Chrome process by Chrome DevTools Protocol,
@@ -45,6 +47,8 @@ const (
 //
 // If chromeURL is empty the function starts a new Chrome process by calling command "google-chrome" (must be in $PATH).
 // If chromeURL is defined the function connects with a remote Chrome process that is accessible on this URL.
+// If the parent context contains a Session (see WithSession) the function does not connect with a Chrome process,
+// and opens a new tab in an isolated browser context of the session's Chrome process; chromeURL is not used in this case.
 //
 // The function returns a context and cancelation function.
 // You should use the context to execute a command in the connected Chrome process.
@@ -52,6 +56,23 @@ const (
 // In the case when the connection established with a new Chrome process
 // the function finishes the Chrome process.
 func ConnectWithContext(parent context.Context, chromeURL string, domains ...Domain) (context.Context, context.CancelFunc, error) {
+	if s := SessionFromContext(parent); s != nil {
+		return s.newTab(parent, domains...)
+	}
+
+	ctx, cancel, err := connect(parent, chromeURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = enableDomains(ctx, domains); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ctx, cancel, nil
+}
+
+// connect establishes a connection with a new or remote Chrome process, and checks Chrome version.
+func connect(parent context.Context, chromeURL string) (context.Context, context.CancelFunc, error) {
 	parent = withInterrupt(parent)
 
 	var (
@@ -74,59 +95,57 @@ func ConnectWithContext(parent context.Context, chromeURL string, domains ...Dom
 		}
 	}
 
-	// Prepare the established connection for the program.
-	// Put the code to a separate function to simplify canceling the connection in error cases.
-	prepareConn := func() error {
-		//
-		// Check Chrome version. The program depends on event's order of Chrome 70 or higher.
-		//
-		var cdpVersion, product string
-		versionAction := chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			cdpVersion, product, _, _, _, err = browser.GetVersion().Do(ctx)
-			return err
-		})
-		if err := chromedp.Run(ctx, versionAction); err != nil {
-			return errors.Wrap(err, "get Chrome version")
-		}
-		debugger.Debugf("Chrome info:\n\tprotocolVersion: %s\n\tproduct:         %s\n", cdpVersion, product)
-		major, err := majorVersion(product)
-		if err != nil {
-			return errors.New("invalid Chrome version %q", product)
-		}
-		if major < 70 {
-			return errors.New("unsupported Chrome version %q", product)
-		}
-
-		//
-		// Activate requested Chrome DevTools Protocol domains.
-		//
-		domains = append([]Domain{domainPage}, domains...)
-		var acts []chromedp.Action
-		for _, dm := range domains {
-			switch dm {
-			case domainPage:
-				acts = append(acts, page.Enable())
-			case DomainNetwork:
-				acts = append(acts, network.Enable())
-			case DomainRuntime:
-				acts = append(acts, runtime.Enable())
-			}
-		}
-		if err := chromedp.Run(ctx, acts...); err != nil {
-			return errors.Wrap(err, "activate CDP domains")
-		}
-
-		return nil
-	}
-	if err := prepareConn(); err != nil {
+	if err := checkVersion(ctx); err != nil {
 		cancel()
 		return nil, nil, err
 	}
-
 	return ctx, cancel, nil
 }
 
+// checkVersion checks Chrome version. The program depends on event's order of Chrome 70 or higher.
+func checkVersion(ctx context.Context) error {
+	var cdpVersion, product string
+	versionAction := chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cdpVersion, product, _, _, _, err = browser.GetVersion().Do(ctx)
+		return err
+	})
+	if err := chromedp.Run(ctx, versionAction); err != nil {
+		return errors.Wrap(err, "get Chrome version")
+	}
+	log.DebuggerFromContext(ctx).Debugf("Chrome info:\n\tprotocolVersion: %s\n\tproduct:         %s\n", cdpVersion, product)
+	major, err := majorVersion(product)
+	if err != nil {
+		return errors.New("invalid Chrome version %q", product)
+	}
+	if major < 70 {
+		return errors.New("unsupported Chrome version %q", product)
+	}
+	return nil
+}
+
+// enableDomains activates requested Chrome DevTools Protocol domains in the current tab.
+func enableDomains(ctx context.Context, domains []Domain) error {
+	domains = append([]Domain{domainPage}, domains...)
+	var acts []chromedp.Action
+	for _, dm := range domains {
+		switch dm {
+		case domainPage:
+			acts = append(acts, page.Enable())
+		case DomainNetwork:
+			acts = append(acts, network.Enable())
+		case DomainRuntime:
+			acts = append(acts, runtime.Enable())
+		case DomainWebAuthn:
+			acts = append(acts, enableWebAuthn())
+		}
+	}
+	if err := chromedp.Run(ctx, acts...); err != nil {
+		return errors.Wrap(err, "activate CDP domains")
+	}
+	return nil
+}
+
 // connectToRemoteChrome connect to a remote Chrome process via Chrome DevTool Protocol.
 func connectToRemoteChrome(parent context.Context, chromeURL string) (context.Context, context.CancelFunc, error) {
 	// Chrome provides an URL for a Chrome DevTool Protocol's connection in a configuration
@@ -384,3 +403,119 @@ func Text(ctx context.Context, sel string) (string, error) {
 	}
 	return text, nil
 }
+
+// WaitForElement waits until the current page of a Chrome process contains an element that matches a selector.
+//
+// The function checks the page every 100 milliseconds. When the timeout is 0 the function checks the page once.
+// The function returns errors.Error with the kind errors.KindTimeout when the timeout exceeded.
+func WaitForElement(ctx context.Context, sel string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		has, err := HasElement(ctx, sel)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The page can be navigating at the moment so an evaluation's error means that the element is not found yet.
+		if err == nil && has {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			if err != nil {
+				return err
+			}
+			return errors.New(errors.KindTimeout, "element %q is not found", sel)
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SelectOption selects an option with a value in a select element that matches a selector.
+//
+// The function fires the event "change" as a user's action does.
+func SelectOption(ctx context.Context, sel, value string) error {
+	args, err := jsArgs(sel, value)
+	if err != nil {
+		return err
+	}
+	expr := fmt.Sprintf(`(function(sel, value) {
+		var el = document.querySelector(sel);
+		if (el == null) {
+			return "element is not found";
+		}
+		var found = false;
+		for (var i = 0; i < el.options.length; i++) {
+			if (el.options[i].value === value) {
+				found = true;
+			}
+		}
+		if (!found) {
+			return "option is not found";
+		}
+		el.value = value;
+		el.dispatchEvent(new Event("change", {bubbles: true}));
+		return "";
+	})(%s)`, args)
+	return evalWithMessage(ctx, expr)
+}
+
+// SetChecked checks or unchecks a checkbox or a radio button that matches a selector.
+//
+// The function clicks the element only when its state differs from the required one, so the page's handlers
+// of the event "click" are called as a user's action does.
+func SetChecked(ctx context.Context, sel string, checked bool) error {
+	args, err := jsArgs(sel, checked)
+	if err != nil {
+		return err
+	}
+	expr := fmt.Sprintf(`(function(sel, checked) {
+		var el = document.querySelector(sel);
+		if (el == null) {
+			return "element is not found";
+		}
+		if (el.checked !== checked) {
+			el.click();
+		}
+		return "";
+	})(%s)`, args)
+	return evalWithMessage(ctx, expr)
+}
+
+// Eval evaluates a JavaScript expression on the current page of a Chrome process.
+//
+// The function returns an error when the expression throws an exception. The expression's result is ignored.
+func Eval(ctx context.Context, expr string) error {
+	// The result is read as raw bytes because of an expression can return undefined that is not a JSON value.
+	var res []byte
+	return chromedp.Run(ctx, chromedp.Evaluate(expr, &res))
+}
+
+// evalWithMessage evaluates a JavaScript expression that returns an error message or an empty string on success.
+func evalWithMessage(ctx context.Context, expr string) error {
+	var msg string
+	if err := chromedp.Run(ctx, chromedp.Evaluate(expr, &msg)); err != nil {
+		return err
+	}
+	if msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// jsArgs returns the arguments of a JavaScript function call as JavaScript literals separated by commas.
+//
+// The arguments are encoded to JSON because Go's quoting of strings is not valid JavaScript for some characters.
+func jsArgs(args ...interface{}) (string, error) {
+	literals := make([]string, 0, len(args))
+	for _, arg := range args {
+		b, err := json.Marshal(arg)
+		if err != nil {
+			return "", errors.Wrap(err, "encode argument of JavaScript function")
+		}
+		literals = append(literals, string(b))
+	}
+	return strings.Join(literals, ", "), nil
+}
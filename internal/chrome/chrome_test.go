@@ -0,0 +1,49 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package chrome
+
+import "testing"
+
+func TestJSArgs(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []interface{}
+		want string
+	}{
+		{
+			name: "string and bool",
+			args: []interface{}{"#consent", true},
+			want: `"#consent", true`,
+		},
+		{
+			name: "quotes",
+			args: []interface{}{`input[name="scope"]`},
+			want: `"input[name=\"scope\"]"`,
+		},
+		{
+			name: "control characters",
+			args: []interface{}{"a\a\vb"},
+			want: `"a\u0007\u000bb"`,
+		},
+		{
+			name: "line separator",
+			args: []interface{}{"a\u2028b"},
+			want: `"a\u2028b"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := jsArgs(tc.args...)
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
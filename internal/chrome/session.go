@@ -0,0 +1,123 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package chrome
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+type sessionContextKey struct{}
+
+// Session is a connection with a Chrome process that is shared by many logins and logouts.
+//
+// Each tab of the session is opened in its own browser context (like an incognito window),
+// so tabs do not share cookies and storages, and can be used concurrently.
+type Session struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewSession establishes a connection with a Chrome process that is shared by many logins and logouts.
+//
+// If chromeURL is empty the function starts a new Chrome process by calling command "google-chrome" (must be in $PATH).
+// If chromeURL is defined the function connects with a remote Chrome process that is accessible on this URL.
+//
+// The session must be closed by calling Close.
+func NewSession(parent context.Context, chromeURL string) (*Session, error) {
+	ctx, cancel, err := connect(parent, chromeURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{ctx: ctx, cancel: cancel}, nil
+}
+
+// Close closes the connection with the Chrome process.
+// In the case when the session started a new Chrome process the function finishes the Chrome process.
+func (s *Session) Close() {
+	log.DebuggerFromContext(s.ctx).Debugln("Close the Chrome session")
+	s.cancel()
+}
+
+// WithSession returns a new context with a Session.
+// The function ConnectWithContext opens a new tab in the session instead of connecting with a Chrome process
+// when it is called with the returned context.
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, s)
+}
+
+// SessionFromContext returns a Session stored in a context.
+// If the context does not contain a Session the function returns nil.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionContextKey{}).(*Session)
+	return s
+}
+
+// newTab opens a new tab in a new browser context, and activates requested domains.
+//
+// The function returns a context of the tab and cancelation function that closes the tab and disposes
+// its browser context. The tab is also closed when the parent context is canceled.
+func (s *Session) newTab(parent context.Context, domains ...Domain) (context.Context, context.CancelFunc, error) {
+	debugger := log.DebuggerFromContext(parent)
+	browser := chromedp.FromContext(s.ctx).Browser
+	browserCtx := cdp.WithExecutor(parent, browser)
+
+	debugger.Debugln("Open a new tab in an isolated browser context")
+	browserContextID, err := target.CreateBrowserContext().Do(browserCtx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "create browser context")
+	}
+	dispose := func() {
+		// The parent context can be canceled at the moment so a new one is used.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := target.DisposeBrowserContext(browserContextID).Do(cdp.WithExecutor(ctx, browser)); err != nil {
+			debugger.Debugf("Failed to dispose browser context: %s\n", err)
+		}
+	}
+	targetID, err := target.CreateTarget("about:blank").WithBrowserContextID(browserContextID).Do(browserCtx)
+	if err != nil {
+		dispose()
+		return nil, nil, errors.Wrap(err, "create tab")
+	}
+
+	ctx, cancelTab := chromedp.NewContext(s.ctx, chromedp.WithTargetID(targetID))
+	// The tab's context is derived from the session's context, so it does not inherit the parent's values.
+	ctx = log.WithDebugger(ctx, debugger)
+
+	var (
+		once sync.Once
+		done = make(chan struct{})
+	)
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			cancelTab()
+			dispose()
+		})
+	}
+	go func() {
+		select {
+		case <-parent.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	if err = enableDomains(ctx, domains); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ctx, cancel, nil
+}
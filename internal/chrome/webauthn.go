@@ -0,0 +1,153 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/mailru/easyjson/jlexer"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// The package cdproto does not contain the domain "WebAuthn" so its commands are sent by their names.
+// See https://chromedevtools.github.io/devtools-protocol/tot/WebAuthn.
+const (
+	commandWebAuthnEnable                  = "WebAuthn.enable"
+	commandWebAuthnAddVirtualAuthenticator = "WebAuthn.addVirtualAuthenticator"
+	commandWebAuthnAddCredential           = "WebAuthn.addCredential"
+	commandWebAuthnGetCredentials          = "WebAuthn.getCredentials"
+)
+
+// WebAuthnCredential is a credential of a virtual WebAuthn authenticator.
+type WebAuthnCredential struct {
+	ID         []byte // a credential's ID
+	RPID       string // a relying party's ID
+	PrivateKey []byte // an ECDSA P-256 private key in PKCS #8 DER format
+	UserHandle []byte // a user's handle
+	SignCount  int64  // a signature counter
+}
+
+// cdpCredential is the type "WebAuthn.Credential". Binary values are encoded by encoding/json in base64 as CDP requires.
+type cdpCredential struct {
+	CredentialID         []byte `json:"credentialId"`
+	IsResidentCredential bool   `json:"isResidentCredential"`
+	RPID                 string `json:"rpId,omitempty"`
+	PrivateKey           []byte `json:"privateKey"`
+	UserHandle           []byte `json:"userHandle,omitempty"`
+	SignCount            int64  `json:"signCount"`
+}
+
+// cdpMessage is parameters or a result of a CDP command that are encoded and decoded by encoding/json.
+type cdpMessage struct {
+	v interface{}
+}
+
+// MarshalEasyJSON implements easyjson.Marshaler.
+func (m cdpMessage) MarshalEasyJSON(w *jwriter.Writer) {
+	w.Raw(json.Marshal(m.v))
+}
+
+// UnmarshalEasyJSON implements easyjson.Unmarshaler.
+func (m cdpMessage) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	if err := json.Unmarshal(l.Raw(), m.v); err != nil {
+		l.AddError(err)
+	}
+}
+
+// enableWebAuthn returns an action that activates the domain "WebAuthn".
+func enableWebAuthn() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return cdp.Execute(ctx, commandWebAuthnEnable, nil, nil)
+	})
+}
+
+// AddVirtualAuthenticator attaches a virtual authenticator to the current tab of a Chrome process,
+// and adds credentials to the authenticator. The function returns the authenticator's ID.
+//
+// The authenticator is an internal CTAP2 authenticator that supports resident keys (passkeys)
+// and user verification. It confirms a user's presence and verification automatically,
+// so the WebAuthn ceremony is completed without any hardware and user's actions.
+//
+// The domain DomainWebAuthn must be activated.
+func AddVirtualAuthenticator(ctx context.Context, creds ...*WebAuthnCredential) (string, error) {
+	var authenticatorID string
+	act := chromedp.ActionFunc(func(ctx context.Context) error {
+		params := map[string]interface{}{
+			"options": map[string]interface{}{
+				"protocol":                    "ctap2",
+				"transport":                   "internal",
+				"hasResidentKey":              true,
+				"hasUserVerification":         true,
+				"isUserVerified":              true,
+				"automaticPresenceSimulation": true,
+			},
+		}
+		var res struct {
+			AuthenticatorID string `json:"authenticatorId"`
+		}
+		if err := cdp.Execute(ctx, commandWebAuthnAddVirtualAuthenticator, cdpMessage{params}, cdpMessage{&res}); err != nil {
+			return errors.Wrap(err, "add virtual authenticator")
+		}
+		authenticatorID = res.AuthenticatorID
+
+		for _, cred := range creds {
+			params := map[string]interface{}{
+				"authenticatorId": authenticatorID,
+				"credential": &cdpCredential{
+					CredentialID:         cred.ID,
+					IsResidentCredential: true,
+					RPID:                 cred.RPID,
+					PrivateKey:           cred.PrivateKey,
+					UserHandle:           cred.UserHandle,
+					SignCount:            cred.SignCount,
+				},
+			}
+			if err := cdp.Execute(ctx, commandWebAuthnAddCredential, cdpMessage{params}, nil); err != nil {
+				return errors.Wrap(err, "add credential to virtual authenticator")
+			}
+		}
+		return nil
+	})
+	if err := chromedp.Run(ctx, act); err != nil {
+		return "", err
+	}
+	return authenticatorID, nil
+}
+
+// WebAuthnCredentials returns credentials of a virtual authenticator.
+//
+// The credentials' signature counters are increased by the authenticator on every WebAuthn assertion.
+func WebAuthnCredentials(ctx context.Context, authenticatorID string) ([]*WebAuthnCredential, error) {
+	var creds []*WebAuthnCredential
+	act := chromedp.ActionFunc(func(ctx context.Context) error {
+		params := map[string]interface{}{"authenticatorId": authenticatorID}
+		var res struct {
+			Credentials []*cdpCredential `json:"credentials"`
+		}
+		if err := cdp.Execute(ctx, commandWebAuthnGetCredentials, cdpMessage{params}, cdpMessage{&res}); err != nil {
+			return errors.Wrap(err, "get credentials of virtual authenticator")
+		}
+		for _, c := range res.Credentials {
+			creds = append(creds, &WebAuthnCredential{
+				ID:         c.CredentialID,
+				RPID:       c.RPID,
+				PrivateKey: c.PrivateKey,
+				UserHandle: c.UserHandle,
+				SignCount:  c.SignCount,
+			})
+		}
+		return nil
+	})
+	if err := chromedp.Run(ctx, act); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
@@ -21,12 +21,32 @@ const (
 	KindEndpointMissed Kind = "endpoint_is_missed"
 	// KindEndpointInvalid is a kind of an error that happens when OpenID Connect endpoint is invalid.
 	KindEndpointInvalid Kind = "endpoint_is_invalid"
+	// KindDiscoveryFailed is a kind of an error that happens when OpenID Connect Provider's discovery document can not be loaded.
+	KindDiscoveryFailed Kind = "discovery_failed"
+	// KindProviderEndpointMissed is a kind of an error that happens when OpenID Connect Provider does not define a required endpoint.
+	KindProviderEndpointMissed Kind = "provider_endpoint_is_missed"
 	// KindClientIDMissed is a kind of an error that happens when OpenID Connect client ID is not specified.
 	KindClientIDMissed Kind = "client_id_is_missed"
+	// KindClientAuthInvalid is a kind of an error that happens when an unsupported client authentication method is specified.
+	KindClientAuthInvalid Kind = "client_auth_is_invalid"
+	// KindClientSecretMissed is a kind of an error that happens when a client authentication method requires a client's secret
+	// but it is not specified.
+	KindClientSecretMissed Kind = "client_secret_is_missed"
+	// KindClientKeyMissed is a kind of an error that happens when a client authentication method requires a client's private key
+	// but it is not specified.
+	KindClientKeyMissed Kind = "client_key_is_missed"
 	// KindRedirectURIMissed is a kind of an error that happens when OpenID Connect client's redirect URI is not specified.
 	KindRedirectURIMissed Kind = "redirect_uri_is_missed"
 	// KindScopesMissed is a kind of an error that happens when OpenID Connect scopes are not specified.
 	KindScopesMissed Kind = "scopes_are_missed"
+	// KindFlowInvalid is a kind of an error that happens when an unsupported OAuth2 flow is specified.
+	KindFlowInvalid Kind = "flow_is_invalid"
+	// KindRefreshTokenMissed is a kind of an error that happens when a refresh token is not specified.
+	KindRefreshTokenMissed Kind = "refresh_token_is_missed"
+	// KindAccessTokenMissed is a kind of an error that happens when an access token is not specified.
+	KindAccessTokenMissed Kind = "access_token_is_missed"
+	// KindTokenMissed is a kind of an error that happens when a token to introspect or revoke is not specified.
+	KindTokenMissed Kind = "token_is_missed"
 	// KindIDTokenMissed is a kind of an error that happens when ID token is not specified.
 	KindIDTokenMissed Kind = "id_token_is_missed"
 	// KindUsernameMissed is a kind of an error that happens when a username is not specified.
@@ -45,10 +65,83 @@ const (
 	KindSubmitButtonInvalid Kind = "submit_button_selector_is_invalid"
 	// KindErrorMessageMissed is a kind of an error that happens when an error message's selector is not specified.
 	KindErrorMessageMissed Kind = "error_message_selector_is_missed"
+	// KindScriptInvalid is a kind of an error that happens when a login script can not be loaded or has an invalid step.
+	KindScriptInvalid Kind = "script_is_invalid"
+	// KindScriptStepFailed is a kind of an error that happens when a login script's step fails.
+	KindScriptStepFailed Kind = "script_step_failed"
+	// KindOTPSecretInvalid is a kind of an error that happens when a TOTP secret can not be read or decoded.
+	KindOTPSecretInvalid Kind = "otp_secret_is_invalid"
+	// KindOTPFieldMissed is a kind of an error that happens when a one-time password field's selector is not specified.
+	KindOTPFieldMissed Kind = "otp_field_selector_is_missed"
+	// KindOTPSubmitMissed is a kind of an error that happens when a one-time password submit button's selector is not specified.
+	KindOTPSubmitMissed Kind = "otp_submit_selector_is_missed"
+	// KindOTPRejected is a kind of an error that happens when an OpenID Connect Provider rejects a one-time password.
+	KindOTPRejected Kind = "otp_rejected"
+	// KindEngineInvalid is a kind of an error that happens when a login engine is not supported,
+	// or it does not support a requested feature.
+	KindEngineInvalid Kind = "engine_is_invalid"
+	// KindUsersInvalid is a kind of an error that happens when a users file of the batch login is invalid.
+	KindUsersInvalid Kind = "users_file_is_invalid"
+	// KindBenchInvalid is a kind of an error that happens when a configuration of the load test is invalid.
+	KindBenchInvalid Kind = "bench_config_is_invalid"
+	// KindCacheInvalid is a kind of an error that happens when the token cache can not be read.
+	KindCacheInvalid Kind = "cache_is_invalid"
+	// KindCacheKeyInvalid is a kind of an error that happens when the token cache is encrypted
+	// and the cache key is missed or wrong.
+	KindCacheKeyInvalid Kind = "cache_key_is_invalid"
+	// KindOutputInvalid is a kind of an error that happens when an output format or template is not valid.
+	KindOutputInvalid Kind = "output_is_invalid"
+	// KindWebAuthnCredentialInvalid is a kind of an error that happens when a stored WebAuthn credential is invalid.
+	KindWebAuthnCredentialInvalid Kind = "webauthn_credential_is_invalid"
+	// KindWebAuthnButtonInvalid is a kind of an error that happens when the login page does not contain
+	// the button that starts the WebAuthn ceremony.
+	KindWebAuthnButtonInvalid Kind = "webauthn_button_selector_is_invalid"
+	// KindConsentButtonInvalid is a kind of an error that happens when the consent page does not contain
+	// the approve or deny button.
+	KindConsentButtonInvalid Kind = "consent_button_selector_is_invalid"
+	// KindConsentScopeInvalid is a kind of an error that happens when the consent page does not offer a scope to grant.
+	KindConsentScopeInvalid Kind = "consent_scope_is_invalid"
 	// KindOIDCError is a kind of an error that is an OpenID Connect errors.
 	KindOIDCError Kind = "openid_connect_error"
 	// KindLoginError is a kind of an error that happens when authentication failed, for example, when username or password are invalid.
 	KindLoginError Kind = "login_error"
+	// KindLogoutError is a kind of an error that happens when the OpenID Connect Provider does not redirect a user
+	// to the post logout redirect URI after the logout.
+	KindLogoutError Kind = "logout_error"
+	// KindStateMismatch is a kind of an error that happens when the authentication callback's state
+	// or the post logout redirect's state does not match the request's state.
+	KindStateMismatch Kind = "state_mismatch"
+	// KindNonceMismatch is a kind of an error that happens when the ID token's nonce does not match
+	// the authentication request's nonce.
+	KindNonceMismatch Kind = "nonce_mismatch"
+	// KindJWKSFailed is a kind of an error that happens when OpenID Connect Provider's JWK Set can not be loaded.
+	KindJWKSFailed Kind = "jwks_failed"
+	// KindIDTokenInvalid is a kind of an error that happens when an ID token is missed or malformed.
+	KindIDTokenInvalid Kind = "id_token_is_invalid"
+	// KindTokenInvalid is a kind of an error that happens when a token is not a JSON Web Token.
+	KindTokenInvalid Kind = "token_is_invalid"
+	// KindSignatureInvalid is a kind of an error that happens when an ID token's signature can not be verified.
+	KindSignatureInvalid Kind = "signature_is_invalid"
+	// KindIssuerMismatch is a kind of an error that happens when an ID token's issuer does not match OpenID Connect Provider's issuer.
+	KindIssuerMismatch Kind = "issuer_mismatch"
+	// KindAudienceMismatch is a kind of an error that happens when an ID token's audience does not contain the client ID.
+	KindAudienceMismatch Kind = "audience_mismatch"
+	// KindAuthorizedPartyMismatch is a kind of an error that happens when an ID token's authorized party is not the client.
+	KindAuthorizedPartyMismatch Kind = "authorized_party_mismatch"
+	// KindTokenExpired is a kind of an error that happens when a token is expired.
+	KindTokenExpired Kind = "token_expired"
+	// KindIssuedAtInvalid is a kind of an error that happens when an ID token is issued in the future.
+	KindIssuedAtInvalid Kind = "issued_at_is_invalid"
+	// KindAtHashMismatch is a kind of an error that happens when an ID token's at_hash does not match the access token.
+	KindAtHashMismatch Kind = "at_hash_mismatch"
+	// KindAtHashMissed is a kind of an error that happens when an ID token issued in the implicit flow
+	// does not contain at_hash.
+	KindAtHashMissed Kind = "at_hash_is_missed"
+	// KindSubjectMismatch is a kind of an error that happens when the UserInfo response's subject
+	// does not match the ID token's subject.
+	KindSubjectMismatch Kind = "subject_mismatch"
+	// KindClaimMismatch is a kind of an error that happens when a token's claim does not match an expected value.
+	KindClaimMismatch Kind = "claim_mismatch"
 	// KindTimeout is a kind of an error that happens when page loading exceeds a timeout.
 	KindTimeout Kind = "timeout"
 )
@@ -132,6 +225,22 @@ func Cause(err error) error {
 	return Cause(v.cause)
 }
 
+// KindOf returns the kind of an error. When the error wraps errors the function returns the first kind
+// that is not KindOther.
+func KindOf(err error) Kind {
+	for err != nil {
+		v, ok := err.(*Error)
+		if !ok {
+			return KindOther
+		}
+		if v.Kind != KindOther {
+			return v.Kind
+		}
+		err = v.cause
+	}
+	return KindOther
+}
+
 // Match returns true when specified errors are similar, and false when they are not.
 // The errors are considered similar when they have the type "Error",
 // and values of fields "kind" and "param" of the "want" error equal to values of the same fields of the "got" error.
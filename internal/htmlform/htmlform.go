@@ -0,0 +1,318 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package htmlform finds elements of HTML pages by CSS selectors, and submits HTML forms without a browser.
+package htmlform
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/i-core/tokget/internal/errors"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Page is a parsed HTML page.
+type Page struct {
+	URL  *url.URL // the page's URL that is used to resolve the URLs of forms' actions
+	root *html.Node
+}
+
+// Parse parses an HTML page that is loaded from an URL.
+func Parse(u *url.URL, r io.Reader) (*Page, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse HTML page")
+	}
+	return &Page{URL: u, root: root}, nil
+}
+
+// HTML returns the page's content.
+func (p *Page) HTML() string {
+	var buf bytes.Buffer
+	if err := html.Render(&buf, p.root); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// HasElement returns true when the page contains an element that matches a selector.
+func (p *Page) HasElement(sel string) (bool, error) {
+	n, err := p.find(sel)
+	if err != nil {
+		return false, err
+	}
+	return n != nil, nil
+}
+
+// Text returns text of an element that matches a selector.
+// The function returns an empty string when the page does not contain the element.
+func (p *Page) Text(sel string) (string, error) {
+	n, err := p.find(sel)
+	if err != nil || n == nil {
+		return "", err
+	}
+	return text(n), nil
+}
+
+// Form returns a form that is submitted by a button that matches a selector.
+//
+// The form's values are initialized by the values of the form's controls as a browser does it.
+// The function returns nil when the page does not contain the button or the button does not belong to a form.
+func (p *Page) Form(button string) (*Form, error) {
+	btn, err := p.find(button)
+	if err != nil || btn == nil {
+		return nil, err
+	}
+	form := p.owner(btn)
+	if form == nil {
+		return nil, nil
+	}
+	f := &Form{page: p, node: form, button: btn, values: url.Values{}}
+	for _, n := range p.controls(form) {
+		name := attr(n, "name")
+		if name == "" || hasAttr(n, "disabled") {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Input:
+			switch strings.ToLower(attr(n, "type")) {
+			case "submit", "image", "button", "reset", "file":
+			case "checkbox", "radio":
+				if hasAttr(n, "checked") {
+					f.values.Add(name, checkboxValue(n))
+				}
+			default:
+				f.values.Add(name, attr(n, "value"))
+			}
+		case atom.Textarea:
+			f.values.Add(name, text(n))
+		case atom.Select:
+			if v, ok := selectedOption(n); ok {
+				f.values.Add(name, v)
+			}
+		}
+	}
+	return f, nil
+}
+
+// find returns the first element that matches a selector, or nil.
+func (p *Page) find(sel string) (*html.Node, error) {
+	s, err := cascadia.Compile(sel)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CSS selector %q", sel)
+	}
+	return s.MatchFirst(p.root), nil
+}
+
+// owner returns the form that an element belongs to, or nil.
+func (p *Page) owner(n *html.Node) *html.Node {
+	if id := attr(n, "form"); id != "" {
+		return cascadia.Selector(func(n *html.Node) bool {
+			return n.DataAtom == atom.Form && attr(n, "id") == id
+		}).MatchFirst(p.root)
+	}
+	for v := n.Parent; v != nil; v = v.Parent {
+		if v.DataAtom == atom.Form {
+			return v
+		}
+	}
+	return nil
+}
+
+// controls returns the controls that belong to a form in the document's order.
+func (p *Page) controls(form *html.Node) []*html.Node {
+	return cascadia.Selector(func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		switch n.DataAtom {
+		case atom.Input, atom.Textarea, atom.Select:
+			return p.owner(n) == form
+		}
+		return false
+	}).MatchAll(p.root)
+}
+
+// Form is an HTML form that is filled and submitted without a browser.
+type Form struct {
+	page   *Page
+	node   *html.Node
+	button *html.Node
+	values url.Values
+}
+
+// Fill sets the value of a form's field that matches a selector.
+//
+// The function returns an error when the page does not contain the field,
+// or the field does not belong to the form, or the field does not have a name.
+func (f *Form) Fill(sel, value string) error {
+	n, err := f.control(sel)
+	if err != nil {
+		return err
+	}
+	f.values.Set(attr(n, "name"), value)
+	return nil
+}
+
+// SetChecked checks the form's checkboxes that match a selector when the function check returns true
+// for a checkbox's value, and unchecks the rest. The function returns the values of all matched checkboxes.
+func (f *Form) SetChecked(sel string, check func(value string) bool) ([]string, error) {
+	s, err := cascadia.Compile(sel)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid CSS selector %q", sel)
+	}
+	var offered []string
+	for _, n := range s.MatchAll(f.page.root) {
+		name := attr(n, "name")
+		if name == "" || f.page.owner(n) != f.node {
+			continue
+		}
+		v := checkboxValue(n)
+		offered = append(offered, v)
+		values := f.values[name][:0]
+		for _, cur := range f.values[name] {
+			if cur != v {
+				values = append(values, cur)
+			}
+		}
+		if check(v) {
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			f.values.Del(name)
+		} else {
+			f.values[name] = values
+		}
+	}
+	return offered, nil
+}
+
+// Request returns a request that submits the form by its button.
+func (f *Form) Request(ctx context.Context) (*http.Request, error) {
+	action := attr(f.button, "formaction")
+	if action == "" {
+		action = attr(f.node, "action")
+	}
+	method := attr(f.button, "formmethod")
+	if method == "" {
+		method = attr(f.node, "method")
+	}
+	ref, err := url.Parse(strings.TrimSpace(action))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse form's action")
+	}
+	u := f.page.URL.ResolveReference(ref)
+	u.Fragment = ""
+
+	values := url.Values{}
+	for k, v := range f.values {
+		values[k] = append([]string(nil), v...)
+	}
+	if name := attr(f.button, "name"); name != "" {
+		values.Add(name, attr(f.button, "value"))
+	}
+
+	var r *http.Request
+	if strings.EqualFold(method, http.MethodPost) {
+		if r, err = http.NewRequest(http.MethodPost, u.String(), strings.NewReader(values.Encode())); err != nil {
+			return nil, errors.Wrap(err, "create form's request")
+		}
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		u.RawQuery = values.Encode()
+		if r, err = http.NewRequest(http.MethodGet, u.String(), nil); err != nil {
+			return nil, errors.Wrap(err, "create form's request")
+		}
+	}
+	return r.WithContext(ctx), nil
+}
+
+// control returns the form's control that matches a selector.
+func (f *Form) control(sel string) (*html.Node, error) {
+	n, err := f.page.find(sel)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, errors.New("the page does not contain element %q", sel)
+	}
+	if f.page.owner(n) != f.node {
+		return nil, errors.New("element %q does not belong to the submitted form", sel)
+	}
+	if attr(n, "name") == "" {
+		return nil, errors.New("element %q does not have a name", sel)
+	}
+	return n, nil
+}
+
+// selectedOption returns the value of a select element's selected option, or the first option.
+func selectedOption(n *html.Node) (string, bool) {
+	options := cascadia.Selector(func(n *html.Node) bool { return n.DataAtom == atom.Option }).MatchAll(n)
+	if len(options) == 0 {
+		return "", false
+	}
+	opt := options[0]
+	for _, v := range options {
+		if hasAttr(v, "selected") {
+			opt = v
+			break
+		}
+	}
+	if v, ok := attrValue(opt, "value"); ok {
+		return v, true
+	}
+	return strings.TrimSpace(text(opt)), true
+}
+
+// checkboxValue returns the value of a checkbox or a radio button. The default value is "on".
+func checkboxValue(n *html.Node) string {
+	if v, ok := attrValue(n, "value"); ok {
+		return v
+	}
+	return "on"
+}
+
+// text returns the text content of a node.
+func text(n *html.Node) string {
+	var buf bytes.Buffer
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return buf.String()
+}
+
+func attr(n *html.Node, key string) string {
+	v, _ := attrValue(n, key)
+	return v
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	_, ok := attrValue(n, key)
+	return ok
+}
+
+func attrValue(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package htmlform
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPage = `
+<html>
+	<body>
+		<form id="login" method="POST" action="/login?tenant=acme">
+			<input type="hidden" name="csrf" value="token"/>
+			<input id="user" name="username"/>
+			<input id="pass" name="password" type="password"/>
+			<input name="disabled" value="x" disabled/>
+			<input name="remember" type="checkbox" checked/>
+			<input name="scope" type="checkbox" value="openid"/>
+			<input name="scope" type="checkbox" value="email" checked/>
+			<select name="lang"><option>en</option><option value="ru" selected>Russian</option></select>
+			<textarea name="note">hello</textarea>
+			<button id="submit" name="action" value="login">login</button>
+			<button id="cancel" formaction="/cancel" formmethod="get">cancel</button>
+		</form>
+		<input id="outside" name="outside"/>
+		<input id="linked" name="linked" form="login" value="yes"/>
+		<p class="message"> invalid password </p>
+	</body>
+</html>
+`
+
+func TestForm(t *testing.T) {
+	u, err := url.Parse("http://op/auth?client_id=1#fragment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := Parse(u, strings.NewReader(testPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := page.Text("p.message")
+	if err != nil || text != " invalid password " {
+		t.Fatalf("got text %q (%v), want %q", text, err, " invalid password ")
+	}
+	if has, err := page.HasElement("#absent"); err != nil || has {
+		t.Fatalf("got element #absent")
+	}
+	if _, err = page.HasElement("[[invalid"); err == nil {
+		t.Fatalf("got no errors for an invalid selector")
+	}
+
+	form, err := page.Form("#submit")
+	if err != nil || form == nil {
+		t.Fatalf("got no form (%v)", err)
+	}
+	if err = form.Fill("#user", "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err = form.Fill("#pass", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err = form.Fill("#outside", "baz"); err == nil {
+		t.Fatalf("got no errors for a field outside the form")
+	}
+	offered, err := form.SetChecked("input[name=scope]", func(v string) bool { return v == "openid" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"openid", "email"}; !reflect.DeepEqual(offered, want) {
+		t.Fatalf("got offered %q, want %q", offered, want)
+	}
+
+	r, err := form.Request(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "POST" || r.URL.String() != "http://op/login?tenant=acme" {
+		t.Fatalf("got request %s %s, want POST http://op/login?tenant=acme", r.Method, r.URL)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"csrf":     {"token"},
+		"username": {"foo"},
+		"password": {"bar"},
+		"remember": {"on"},
+		"scope":    {"openid"},
+		"lang":     {"ru"},
+		"note":     {"hello"},
+		"linked":   {"yes"},
+		"action":   {"login"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got form data %v, want %v", got, want)
+	}
+
+	cancel, err := page.Form("#cancel")
+	if err != nil || cancel == nil {
+		t.Fatalf("got no form (%v)", err)
+	}
+	r, err = cancel.Request(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "GET" || r.URL.Path != "/cancel" || r.URL.Query().Get("csrf") != "token" {
+		t.Fatalf("got request %s %s, want GET http://op/cancel with the form's data", r.Method, r.URL)
+	}
+}
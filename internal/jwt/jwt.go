@@ -0,0 +1,310 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package jwt implements JSON Web Tokens that are signed by JSON Web Signature (JWS Compact Serialization).
+//
+// See https://tools.ietf.org/html/rfc7519 and https://tools.ietf.org/html/rfc7515.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // registers SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/i-core/tokget/internal/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Header is a JOSE header of a token.
+type Header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Token is a decoded token.
+type Token struct {
+	Raw       string                 // the encoded token
+	Header    *Header                // the token's JOSE header
+	Claims    map[string]interface{} // the token's claims; numbers are decoded as json.Number
+	Signature []byte                 // the token's signature
+
+	signingInput string
+}
+
+// Parse decodes a token without verifying its signature.
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must contain 3 parts separated by dots, got %d", len(parts))
+	}
+	hb, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode header")
+	}
+	header := &Header{}
+	if err = json.Unmarshal(hb, header); err != nil {
+		return nil, errors.Wrap(err, "parse header")
+	}
+	cb, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode claims")
+	}
+	var claims map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(cb))
+	dec.UseNumber()
+	if err = dec.Decode(&claims); err != nil {
+		return nil, errors.Wrap(err, "parse claims")
+	}
+	sig, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode signature")
+	}
+	return &Token{
+		Raw:          raw,
+		Header:       header,
+		Claims:       claims,
+		Signature:    sig,
+		signingInput: parts[0] + "." + parts[1],
+	}, nil
+}
+
+// String returns a string claim. The function returns an empty string when the claim is missed or is not a string.
+func (t *Token) String(name string) string {
+	v, _ := t.Claims[name].(string)
+	return v
+}
+
+// Int returns a numeric claim. The second returned value is false when the claim is missed or is not an integer.
+func (t *Token) Int(name string) (int64, bool) {
+	v, ok := t.Claims[name].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	if i, err := v.Int64(); err == nil {
+		return i, true
+	}
+	// A numeric date can contain a fraction.
+	f, err := v.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// Audience returns the claim "aud". By the specification the claim is a string or an array of strings.
+func (t *Token) Audience() []string {
+	switch v := t.Claims["aud"].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var aud []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				aud = append(aud, s)
+			}
+		}
+		return aud
+	}
+	return nil
+}
+
+// Verify checks the token's signature with a public key by the algorithm from the token's header.
+//
+// The key must be *rsa.PublicKey for RS* and PS* algorithms, *ecdsa.PublicKey for ES* algorithms,
+// and ed25519.PublicKey for EdDSA. The algorithm "none" is never accepted.
+func (t *Token) Verify(key interface{}) error {
+	alg, data, sig := t.Header.Alg, []byte(t.signingInput), t.Signature
+	switch alg {
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("algorithm %s requires an RSA public key", alg)
+		}
+		h := hashes[alg]
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(rsaKey, h, hashSum(h, data), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, h, hashSum(h, data), sig)
+	case "ES256", "ES384", "ES512":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("algorithm %s requires an EC public key", alg)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature size %d", len(sig))
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(ecKey, hashSum(hashes[alg], data), r, s) {
+			return errors.New("ECDSA verification error")
+		}
+		return nil
+	case "EdDSA":
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("algorithm %s requires an Ed25519 public key", alg)
+		}
+		if !ed25519.Verify(edKey, data, sig) {
+			return errors.New("Ed25519 verification error")
+		}
+		return nil
+	}
+	return errors.New("unsupported algorithm %q", alg)
+}
+
+// LeftHash returns the base64url-encoded left half of a value's hash. The hash function is chosen
+// by the algorithm from the token's header. This is the way the claims "at_hash" and "c_hash" are calculated.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken.
+func (t *Token) LeftHash(value string) (string, error) {
+	h, ok := hashes[t.Header.Alg]
+	if t.Header.Alg == "EdDSA" {
+		// Ed25519 uses SHA-512 internally.
+		h, ok = crypto.SHA512, true
+	}
+	if !ok {
+		return "", errors.New("unsupported algorithm %q", t.Header.Alg)
+	}
+	sum := hashSum(h, []byte(value))
+	return encodeSegment(sum[:len(sum)/2]), nil
+}
+
+// Sign returns a token that contains claims and is signed with a key by an algorithm from a header.
+//
+// The key must be []byte for HMAC algorithms (HS256, HS384, HS512), *rsa.PrivateKey for RS* and PS* algorithms,
+// *ecdsa.PrivateKey for ES* algorithms, and ed25519.PrivateKey for EdDSA.
+func Sign(header *Header, claims interface{}, key interface{}) (string, error) {
+	hb, err := json.Marshal(header)
+	if err != nil {
+		return "", errors.Wrap(err, "encode header")
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "encode claims")
+	}
+	signingInput := encodeSegment(hb) + "." + encodeSegment(cb)
+	sig, err := sign(header.Alg, []byte(signingInput), key)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encodeSegment(sig), nil
+}
+
+// DefaultAlg returns a JWS algorithm that is used by default for a private key.
+func DefaultAlg(key interface{}) string {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 384:
+			return "ES384"
+		case 521:
+			return "ES512"
+		}
+		return "ES256"
+	case ed25519.PrivateKey:
+		return "EdDSA"
+	}
+	return ""
+}
+
+// sign returns a signature of data that is calculated with a key by an algorithm.
+func sign(alg string, data []byte, key interface{}) ([]byte, error) {
+	switch alg {
+	case "HS256", "HS384", "HS512":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, errors.New("algorithm %s requires a secret", alg)
+		}
+		mac := hmac.New(hashes[alg].New, secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("algorithm %s requires an RSA private key", alg)
+		}
+		h := hashes[alg]
+		digest := hashSum(h, data)
+		if alg[0] == 'P' {
+			return rsa.SignPSS(rand.Reader, rsaKey, h, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, h, digest)
+	case "ES256", "ES384", "ES512":
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("algorithm %s requires an EC private key", alg)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, hashSum(hashes[alg], data))
+		if err != nil {
+			return nil, errors.Wrap(err, "sign")
+		}
+		// JWS uses a concatenation of R and S that are padded to the curve's size.
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[size-len(rb):size], rb)
+		copy(sig[2*size-len(sb):], sb)
+		return sig, nil
+	case "EdDSA":
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("algorithm %s requires an Ed25519 private key", alg)
+		}
+		return ed25519.Sign(edKey, data), nil
+	}
+	return nil, errors.New("unsupported algorithm %q", alg)
+}
+
+// hashes maps JWS algorithms to their hash functions.
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+func hashSum(h crypto.Hash, data []byte) []byte {
+	hh := h.New()
+	hh.Write(data)
+	return hh.Sum(nil)
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer.
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := decodeSegment(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
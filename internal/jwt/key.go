@@ -0,0 +1,308 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package jwt
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+
+	"github.com/i-core/tokget/internal/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+// JWK is a JSON Web Key.
+//
+// See https://tools.ietf.org/html/rfc7517 and https://tools.ietf.org/html/rfc8037.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys.
+	N  string `json:"n,omitempty"`
+	E  string `json:"e,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+	// EC and OKP keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// The private part of RSA, EC and OKP keys.
+	D string `json:"d,omitempty"`
+}
+
+// PrivateKey returns a private key that the JWK contains.
+//
+// The function returns *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey.
+func (k *JWK) PrivateKey() (interface{}, error) {
+	if k.D == "" {
+		return nil, errors.New("JWK %q does not contain a private key", k.Kid)
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA modulus")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA exponent")
+		}
+		d, err := decodeBigInt(k.D)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA private exponent")
+		}
+		p, err := decodeBigInt(k.P)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA prime")
+		}
+		q, err := decodeBigInt(k.Q)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA prime")
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err = key.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid RSA key")
+		}
+		key.Precompute()
+		return key, nil
+	case "EC":
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		d, err := decodeBigInt(k.D)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode EC private key")
+		}
+		key := &ecdsa.PrivateKey{D: d}
+		key.Curve = curve
+		key.X, key.Y = curve.ScalarBaseMult(d.Bytes())
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.New("unsupported OKP curve %q", k.Crv)
+		}
+		seed, err := decodeSegment(k.D)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode Ed25519 private key")
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key size %d", len(seed))
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	return nil, errors.New("unsupported key type %q", k.Kty)
+}
+
+// PublicKey returns a public key that the JWK contains.
+//
+// The function returns *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (k *JWK) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA modulus")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode EC point")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode EC point")
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.New("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := decodeSegment(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode Ed25519 public key")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, errors.New("unsupported key type %q", k.Kty)
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseJWKS parses a JSON Web Key Set.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var jwks JWKS
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.Wrap(err, "parse JWKS")
+	}
+	return &jwks, nil
+}
+
+// Candidates returns public keys of the set that can verify a token with a header.
+//
+// A key is a candidate when its key ID, type and intended use match the header.
+// When the header does not contain a key ID all keys of the matching type are candidates.
+func (s *JWKS) Candidates(header *Header) []interface{} {
+	var keys []interface{}
+	for i := range s.Keys {
+		k := &s.Keys[i]
+		if header.Kid != "" && k.Kid != header.Kid {
+			continue
+		}
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if k.Alg != "" && k.Alg != header.Alg {
+			continue
+		}
+		if k.Kty != keyType(header.Alg) {
+			continue
+		}
+		key, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// keyType returns a JWK key type that is used by a JWS algorithm.
+func keyType(alg string) string {
+	switch {
+	case alg == "EdDSA":
+		return "OKP"
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		return "RSA"
+	case strings.HasPrefix(alg, "ES"):
+		return "EC"
+	case strings.HasPrefix(alg, "HS"):
+		return "oct"
+	}
+	return ""
+}
+
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	}
+	return nil, errors.New("unsupported EC curve %q", crv)
+}
+
+// ParsePrivateKey parses a private key in PEM (PKCS #1, PKCS #8 or SEC 1) or JWK format.
+//
+// The function returns the key and its key ID. The key ID is defined only when the key is in JWK format.
+func ParsePrivateKey(data []byte) (interface{}, string, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var jwk JWK
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			return nil, "", errors.Wrap(err, "parse JWK")
+		}
+		key, err := jwk.PrivateKey()
+		if err != nil {
+			return nil, "", err
+		}
+		return key, jwk.Kid, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, "", errors.New("the key is neither PEM nor JWK")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "parse PKCS #1 private key")
+		}
+		return key, "", nil
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "parse EC private key")
+		}
+		return key, "", nil
+	case "PRIVATE KEY":
+		if key, err := parseEd25519PKCS8(block.Bytes); err == nil {
+			return key, "", nil
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "parse PKCS #8 private key")
+		}
+		return key, "", nil
+	}
+	return nil, "", errors.New("unsupported PEM block %q", block.Type)
+}
+
+// oidEd25519 is the object identifier of Ed25519 keys. See https://tools.ietf.org/html/rfc8410.
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// parseEd25519PKCS8 parses an Ed25519 private key in PKCS #8 format.
+//
+// Package crypto/x509 of Go 1.12 does not support Ed25519 keys so the function parses the key manually.
+func parseEd25519PKCS8(der []byte) (ed25519.PrivateKey, error) {
+	var pkcs8 struct {
+		Version    int
+		Algo       struct{ Algorithm asn1.ObjectIdentifier }
+		PrivateKey []byte
+	}
+	if _, err := asn1.Unmarshal(der, &pkcs8); err != nil {
+		return nil, err
+	}
+	if !pkcs8.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	var seed []byte
+	if _, err := asn1.Unmarshal(pkcs8.PrivateKey, &seed); err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid Ed25519 private key size %d", len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
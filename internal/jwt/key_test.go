@@ -0,0 +1,122 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %s", err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("failed to marshal EC key: %s", err)
+	}
+	rsaPKCS8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("failed to marshal RSA key: %s", err)
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err = rand.Read(seed); err != nil {
+		t.Fatalf("failed to generate Ed25519 seed: %s", err)
+	}
+	edKey := ed25519.NewKeyFromSeed(seed)
+	// The PKCS #8 prefix of an Ed25519 private key from https://tools.ietf.org/html/rfc8410#section-10.3.
+	edPrefix, _ := hex.DecodeString("302e020100300506032b657004220420")
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	pemBlock := func(typ string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	}
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		want    interface{}
+		wantKid string
+		wantErr bool
+	}{
+		{name: "invalid data", data: []byte("foo"), wantErr: true},
+		{name: "unsupported PEM block", data: pemBlock("CERTIFICATE", []byte{}), wantErr: true},
+		{name: "PKCS #1", data: pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), want: rsaKey},
+		{name: "PKCS #8 RSA", data: pemBlock("PRIVATE KEY", rsaPKCS8), want: rsaKey},
+		{name: "SEC 1", data: pemBlock("EC PRIVATE KEY", ecDER), want: ecKey},
+		{name: "PKCS #8 Ed25519", data: pemBlock("PRIVATE KEY", append(edPrefix, seed...)), want: edKey},
+		{
+			name: "JWK RSA",
+			data: []byte(fmt.Sprintf(`{"kty":"RSA","kid":"rsa-1","n":%q,"e":"AQAB","d":%q,"p":%q,"q":%q}`,
+				b64(rsaKey.N.Bytes()), b64(rsaKey.D.Bytes()), b64(rsaKey.Primes[0].Bytes()), b64(rsaKey.Primes[1].Bytes()))),
+			want:    rsaKey,
+			wantKid: "rsa-1",
+		},
+		{
+			name: "JWK EC",
+			data: []byte(fmt.Sprintf(`{"kty":"EC","kid":"ec-1","crv":"P-256","x":%q,"y":%q,"d":%q}`,
+				b64(ecKey.X.Bytes()), b64(ecKey.Y.Bytes()), b64(ecKey.D.Bytes()))),
+			want:    ecKey,
+			wantKid: "ec-1",
+		},
+		{
+			name:    "JWK Ed25519",
+			data:    []byte(fmt.Sprintf(`{"kty":"OKP","kid":"ed-1","crv":"Ed25519","d":%q}`, b64(seed))),
+			want:    edKey,
+			wantKid: "ed-1",
+		},
+		{name: "JWK without private key", data: []byte(`{"kty":"OKP","crv":"Ed25519","x":"AAAA"}`), wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, kid, err := ParsePrivateKey(tc.data)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got no errors, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %q, want no errors", err)
+			}
+			if kid != tc.wantKid {
+				t.Fatalf("got key ID %q, want %q", kid, tc.wantKid)
+			}
+			if !equalKeys(got, tc.want) {
+				t.Fatalf("got key %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func equalKeys(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *rsa.PrivateKey:
+		b, ok := b.(*rsa.PrivateKey)
+		return ok && a.N.Cmp(b.N) == 0 && a.D.Cmp(b.D) == 0
+	case *ecdsa.PrivateKey:
+		b, ok := b.(*ecdsa.PrivateKey)
+		return ok && a.D.Cmp(b.D) == 0 && a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
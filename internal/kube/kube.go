@@ -0,0 +1,56 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package kube builds credentials of the kubectl exec credential plugin.
+//
+// See https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins.
+package kube
+
+import (
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+// Identifiers of the ExecCredential object.
+const (
+	APIVersion = "client.authentication.k8s.io/v1"
+	Kind       = "ExecCredential"
+)
+
+// ExecCredential is a credential that an exec credential plugin prints for kubectl.
+type ExecCredential struct {
+	APIVersion string                `json:"apiVersion"`
+	Kind       string                `json:"kind"`
+	Status     *ExecCredentialStatus `json:"status"`
+}
+
+// ExecCredentialStatus contains a bearer token and its expiration time.
+// kubectl caches the token until the expiration time.
+type ExecCredentialStatus struct {
+	ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+	Token               string     `json:"token"`
+}
+
+// NewExecCredential returns a credential with an ID token. The expiration time is taken from the ID token's claim "exp".
+// When the ID token does not contain the claim the credential does not contain the expiration time,
+// and kubectl requests a new credential for each command.
+func NewExecCredential(idToken string) (*ExecCredential, error) {
+	if idToken == "" {
+		return nil, errors.New(errors.KindIDTokenMissed, "ID token is missed")
+	}
+	tok, err := jwt.Parse(idToken)
+	if err != nil {
+		return nil, errors.New(errors.KindIDTokenInvalid, err, "parse ID token")
+	}
+	status := &ExecCredentialStatus{Token: idToken}
+	if exp, ok := tok.Int("exp"); ok {
+		t := time.Unix(exp, 0).UTC()
+		status.ExpirationTimestamp = &t
+	}
+	return &ExecCredential{APIVersion: APIVersion, Kind: Kind, Status: status}, nil
+}
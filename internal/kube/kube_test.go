@@ -0,0 +1,77 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package kube
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestNewExecCredential(t *testing.T) {
+	token := func(claims string) string {
+		enc := base64.RawURLEncoding
+		return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + "."
+	}
+	withExp := token(`{"sub":"foo","exp":1556712000}`)
+	withoutExp := token(`{"sub":"foo"}`)
+
+	testCases := []struct {
+		name    string
+		idToken string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "ID token is missed",
+			wantErr: errors.New(errors.KindIDTokenMissed),
+		},
+		{
+			name:    "ID token is malformed",
+			idToken: "not-a-jwt",
+			wantErr: errors.New(errors.KindIDTokenInvalid),
+		},
+		{
+			name:    "ID token with exp",
+			idToken: withExp,
+			want: `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential",` +
+				`"status":{"expirationTimestamp":"2019-05-01T12:00:00Z","token":"` + withExp + `"}}`,
+		},
+		{
+			name:    "ID token without exp",
+			idToken: withoutExp,
+			want:    `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"` + withoutExp + `"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cred, err := NewExecCredential(tc.idToken)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			b, err := json.Marshal(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != tc.want {
+				t.Fatalf("\ngot:\n\t%s\nwant:\n\t%s", got, tc.want)
+			}
+		})
+	}
+}
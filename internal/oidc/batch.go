@@ -0,0 +1,246 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// User is a user of the batch login.
+type User struct {
+	Username     string `json:"username"`
+	Password     string `json:"password,omitempty"`      // a user's password
+	PasswordFile string `json:"password_file,omitempty"` // a path to a file that contains a user's password
+	PasswordEnv  string `json:"password_env,omitempty"`  // a name of an environment variable that contains a user's password
+	Scopes       string `json:"scopes,omitempty"`        // OpenID Connect scopes separated by spaces or commas; overrides the default scopes
+
+	// line is a line of the users file that defines the user (a record's number in a CSV file).
+	line int
+}
+
+// BatchResult is a result of a user's login in the batch login.
+// It contains either tokens or an error.
+type BatchResult struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	*LoginData
+	Error *BatchError `json:"error,omitempty"`
+}
+
+// BatchError is an error of a user's login in the batch login.
+type BatchError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// LoadUsers loads users of the batch login from a file in CSV or JSON Lines format.
+//
+// The format is chosen by the file's extension: ".csv" is CSV, and any other extension is JSON Lines.
+// The first line of a CSV file is a header that names the columns: username, password, password_file,
+// password_env and scopes. Only the column username is required.
+func LoadUsers(filename string) ([]*User, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.New(errors.KindUsersInvalid, err, "read users")
+	}
+	defer f.Close()
+	var users []*User
+	if strings.ToLower(filepath.Ext(filename)) == ".csv" {
+		users, err = readUsersCSV(f)
+	} else {
+		users, err = readUsersJSONL(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New(errors.KindUsersInvalid, "users file does not contain users")
+	}
+	for _, u := range users {
+		if u.Username == "" {
+			return nil, errors.New(errors.KindUsersInvalid, "line %d: username is missed", u.line)
+		}
+	}
+	return users, nil
+}
+
+func readUsersCSV(r io.Reader) ([]*User, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(errors.KindUsersInvalid, err, "parse users")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "username", "password", "password_file", "password_env", "scopes":
+		default:
+			return nil, errors.New(errors.KindUsersInvalid, "unknown column %q", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, errors.New(errors.KindUsersInvalid, "column \"username\" is missed")
+	}
+
+	var users []*User
+	// The header is the first record, so the users' records start from the second one.
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New(errors.KindUsersInvalid, err, "parse users")
+		}
+		get := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		users = append(users, &User{
+			Username:     get("username"),
+			Password:     get("password"),
+			PasswordFile: get("password_file"),
+			PasswordEnv:  get("password_env"),
+			Scopes:       get("scopes"),
+			line:         line,
+		})
+	}
+	return users, nil
+}
+
+func readUsersJSONL(r io.Reader) ([]*User, error) {
+	var users []*User
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		u := &User{line: line}
+		if err := dec.Decode(u); err != nil {
+			return nil, errors.New(errors.KindUsersInvalid, err, "line %d", line)
+		}
+		users = append(users, u)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.New(errors.KindUsersInvalid, err, "read users")
+	}
+	return users, nil
+}
+
+// LoginBatch logs users in concurrently, and calls the function report for each user's result.
+// The function report is called by one goroutine at a time in the order of finishing logins.
+//
+// The configuration is shared by all users. A user's name, password and scopes override the configuration's ones.
+// When the configuration's password is read from stdin, it is read once before the logins start.
+// The engine chrome uses one Chrome process for all users, and logs each user in a separate browser context.
+//
+// A failed login does not stop the batch. The function returns the number of failed logins,
+// and an error when the batch can not be started.
+func LoginBatch(ctx context.Context, chromeURL string, cnf *LoginConfig, users []*User, concurrency int, report func(*BatchResult)) (int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// The password is requested once for all users without a password source.
+	if cnf.PasswordStdin {
+		password, err := pwdFromStdin()
+		if err != nil {
+			return 0, err
+		}
+		batchCnf := *cnf
+		batchCnf.Password, batchCnf.PasswordStdin = password, false
+		cnf = &batchCnf
+	}
+
+	if cnf.Engine == "" || cnf.Engine == EngineChrome {
+		session, err := chrome.NewSession(ctx, chromeURL)
+		if err != nil {
+			return 0, errors.Wrap(err, "connect to chrome")
+		}
+		defer session.Close()
+		ctx = chrome.WithSession(ctx, session)
+	}
+
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+		queue  = make(chan *User)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				res := loginUser(ctx, chromeURL, cnf, u)
+				mu.Lock()
+				if res.Error != nil {
+					failed++
+				}
+				report(res)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, u := range users {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+	return failed, nil
+}
+
+// loginUser logs a user of the batch login in.
+func loginUser(ctx context.Context, chromeURL string, cnf *LoginConfig, u *User) *BatchResult {
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Log in the user %q (line %d)\n", u.Username, u.line)
+
+	res := &BatchResult{Line: u.line, Username: u.Username}
+	ucnf := *cnf
+	ucnf.Username = u.Username
+	if u.Scopes != "" {
+		ucnf.Scopes = strings.Join(strings.FieldsFunc(u.Scopes, func(r rune) bool { return r == ',' || r == ' ' }), " ")
+	}
+
+	var err error
+	if u.Password != "" || u.PasswordFile != "" || u.PasswordEnv != "" {
+		if ucnf.Password, err = readSecret(u.Password, u.PasswordFile, u.PasswordEnv); err != nil {
+			err = errors.New(errors.KindUsersInvalid, err, "read password")
+		}
+	}
+	if err == nil {
+		res.LoginData, err = Login(ctx, chromeURL, &ucnf)
+	}
+	if err != nil {
+		res.Error = &BatchError{Kind: errors.KindOf(err).String(), Message: err.Error()}
+	}
+	return res
+}
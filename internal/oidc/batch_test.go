@@ -0,0 +1,227 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestLoadUsers(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+		want    []*User
+		wantErr error
+	}{
+		{
+			name:    "empty file",
+			file:    "users.csv",
+			content: "",
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "csv: unknown column",
+			file:    "users.csv",
+			content: "username,secret\nfoo,bar\n",
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "csv: username column is missed",
+			file:    "users.csv",
+			content: "password\nbar\n",
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "csv: username is empty",
+			file:    "users.csv",
+			content: "username,password\nfoo,bar\n,baz\n",
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "jsonl: unknown field",
+			file:    "users.jsonl",
+			content: `{"username": "foo", "secret": "bar"}`,
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "jsonl: invalid json",
+			file:    "users.jsonl",
+			content: `{"username": "foo"`,
+			wantErr: errors.New(errors.KindUsersInvalid),
+		},
+		{
+			name:    "csv",
+			file:    "users.csv",
+			content: "username, password_env, scopes\nfoo, FOO_PASSWORD, \"openid,email\"\nbar,,\n",
+			want: []*User{
+				{Username: "foo", PasswordEnv: "FOO_PASSWORD", Scopes: "openid,email", line: 2},
+				{Username: "bar", line: 3},
+			},
+		},
+		{
+			name: "jsonl",
+			file: "users.jsonl",
+			content: `{"username": "foo", "password": "secret"}
+
+{"username": "bar", "password_file": "/run/secrets/bar", "scopes": "openid profile"}
+`,
+			want: []*User{
+				{Username: "foo", Password: "secret", line: 1},
+				{Username: "bar", PasswordFile: "/run/secrets/bar", Scopes: "openid profile", line: 3},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tokget")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, tc.file)
+			if err = ioutil.WriteFile(filename, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadUsers(filename)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoginBatch(t *testing.T) {
+	passwords := map[string]string{"alice": "alice-secret", "bob": "bob-secret", "carol": "carol-secret", "dave": "stdin-secret"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q}`, issuer, issuer+"/oauth2/auth")
+		case "/oauth2/auth":
+			q := url.Values{}
+			for _, k := range []string{"state", "nonce", "scope"} {
+				q.Set(k, r.URL.Query().Get(k))
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, htmlForm("/handle-auth?"+q.Encode()))
+		case "/handle-auth":
+			if want, ok := passwords[r.FormValue("user")]; !ok || r.FormValue("pass") != want {
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprintln(w, htmlFormWithError("/handle-auth", "invalid password"))
+				return
+			}
+			q := r.URL.Query()
+			fragment := url.Values{}
+			fragment.Set("access_token", r.FormValue("user")+":"+q.Get("scope"))
+			fragment.Set("id_token", testIDToken(q.Get("nonce")))
+			fragment.Set("state", q.Get("state"))
+			http.Redirect(w, r, "http://localhost:9000/auth-callback#"+fragment.Encode(), http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	cnf := &LoginConfig{
+		Endpoint:      srv.URL,
+		Engine:        EngineHTTP,
+		ClientID:      "test-client",
+		RedirectURI:   "http://localhost:9000/auth-callback",
+		Scopes:        "openid",
+		PasswordStdin: true,
+		UsernameField: "#user",
+		PasswordField: "#pass",
+		SubmitButton:  "#submit",
+		ErrorMessage:  "#error",
+	}
+	os.Setenv("TOKGET_TEST_BOB_PASSWORD", "bob-secret")
+	defer os.Unsetenv("TOKGET_TEST_BOB_PASSWORD")
+	users := []*User{
+		{Username: "alice", Password: "alice-secret", Scopes: "openid,email", line: 1},
+		{Username: "bob", PasswordEnv: "TOKGET_TEST_BOB_PASSWORD", line: 2},
+		{Username: "carol", Password: "wrong", line: 3},
+		{Username: "dave", line: 4},
+		{Username: "erin", PasswordFile: "/non-existent", line: 5},
+	}
+
+	// The password from stdin is read once and used by the users without a password source.
+	var stdinReads int
+	v := pwdFromStdin
+	pwdFromStdin = func() (string, error) {
+		stdinReads++
+		return "stdin-secret", nil
+	}
+	defer func() { pwdFromStdin = v }()
+
+	var results []*BatchResult
+	failed, err := LoginBatch(context.Background(), "", cnf, users, 3, func(res *BatchResult) {
+		results = append(results, res)
+	})
+	if err != nil {
+		t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+	}
+	if failed != 2 {
+		t.Errorf("got %d failed logins, want 2", failed)
+	}
+	if stdinReads != 1 {
+		t.Errorf("got %d reads of the password from stdin, want 1", stdinReads)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	type result struct {
+		username    string
+		accessToken string
+		errKind     string
+	}
+	var got []result
+	for _, res := range results {
+		r := result{username: res.Username}
+		if res.LoginData != nil {
+			r.accessToken = res.AccessToken
+		}
+		if res.Error != nil {
+			r.errKind = res.Error.Kind
+		}
+		got = append(got, r)
+	}
+	want := []result{
+		{username: "alice", accessToken: "alice:openid email"},
+		{username: "bob", accessToken: "bob:openid"},
+		{username: "carol", errKind: string(errors.KindLoginError)},
+		{username: "dave", accessToken: "dave:openid"},
+		{username: "erin", errKind: string(errors.KindUsersInvalid)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// Steps of the login process that are timed by Login.
+const (
+	// StepNavigate lasts from the navigation to the authorization endpoint until the login page is loaded.
+	StepNavigate = "navigate"
+	// StepFormReady lasts from loading the login page until the login form is ready to be filled.
+	StepFormReady = "form-ready"
+	// StepSubmit lasts from filling the login form until the page after the login form is loaded.
+	StepSubmit = "submit"
+	// StepCallback lasts from loading the page after the login form until tokens are received by the client.
+	// It includes the second factor, the consent page and the exchange of an authorization code.
+	StepCallback = "callback"
+	// StepTotal is the whole login process. It is timed by Bench for successful logins only.
+	StepTotal = "total"
+)
+
+// benchSteps is the order of steps in a report of the load test.
+var benchSteps = []string{StepNavigate, StepFormReady, StepSubmit, StepCallback, StepTotal}
+
+// StepObserver is a function that is called when a step of the login process is finished.
+type StepObserver func(step string, d time.Duration)
+
+type stepObserverContextKey struct{}
+
+type stepTimerContextKey struct{}
+
+// WithStepObserver returns a new context with a StepObserver.
+// Login that is called with the returned context reports durations of its steps to the observer.
+func WithStepObserver(ctx context.Context, observe StepObserver) context.Context {
+	return context.WithValue(ctx, stepObserverContextKey{}, observe)
+}
+
+// stepTimer measures durations of the login process's steps, and reports them to a StepObserver.
+// Each step is reported once, and lasts from the end of the previous step.
+//
+// A nil stepTimer does nothing, so the login process does not check whether it is timed.
+type stepTimer struct {
+	observe  StepObserver
+	last     time.Time
+	finished map[string]bool
+}
+
+// newStepTimer returns a new stepTimer for a StepObserver stored in a context.
+// If the context does not contain a StepObserver the function returns nil.
+func newStepTimer(ctx context.Context) *stepTimer {
+	observe, _ := ctx.Value(stepObserverContextKey{}).(StepObserver)
+	if observe == nil {
+		return nil
+	}
+	return &stepTimer{observe: observe, last: time.Now(), finished: make(map[string]bool)}
+}
+
+// withStepTimer returns a new context with a stepTimer.
+func withStepTimer(ctx context.Context, t *stepTimer) context.Context {
+	return context.WithValue(ctx, stepTimerContextKey{}, t)
+}
+
+// stepTimerFromContext returns a stepTimer stored in a context.
+// If the context does not contain a stepTimer the function returns nil.
+func stepTimerFromContext(ctx context.Context) *stepTimer {
+	t, _ := ctx.Value(stepTimerContextKey{}).(*stepTimer)
+	return t
+}
+
+// start starts timing of the first step.
+func (t *stepTimer) start() {
+	if t == nil {
+		return
+	}
+	t.last = time.Now()
+}
+
+// finish reports a step's duration unless the step is already reported.
+func (t *stepTimer) finish(step string) {
+	if t == nil || t.finished[step] {
+		return
+	}
+	now := time.Now()
+	t.finished[step] = true
+	t.observe(step, now.Sub(t.last))
+	t.last = now
+}
+
+// BenchConfig is a configuration of the load test of the login process.
+type BenchConfig struct {
+	Login       *LoginConfig  // a configuration of each login
+	Duration    time.Duration // a duration of the test; no new logins are started after it
+	Iterations  int           // a number of logins; the test is not limited by the number of logins when it is 0
+	Concurrency int           // a number of concurrent logins
+}
+
+// BenchReport is a summary of the load test of the login process.
+type BenchReport struct {
+	Elapsed   float64        `json:"elapsed_ms"`        // a duration of the test in milliseconds
+	Logins    int            `json:"logins"`            // a number of finished logins
+	Succeeded int            `json:"succeeded"`         // a number of successful logins
+	Failed    int            `json:"failed"`            // a number of failed logins
+	Rate      float64        `json:"logins_per_second"` // a number of finished logins per second
+	Steps     []*StepStats   `json:"steps"`             // latencies of the login process's steps
+	Errors    map[string]int `json:"errors"`            // numbers of failed logins by error kinds
+}
+
+// StepStats contains latencies of a step of the login process in milliseconds.
+type StepStats struct {
+	Step  string  `json:"step"`
+	Count int     `json:"count"`
+	Min   float64 `json:"min_ms"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Bench runs the login process repeatedly and concurrently for a duration or a number of iterations,
+// and returns latencies of the login process's steps and numbers of errors by kinds.
+//
+// Latencies of a step are collected from all logins that finish the step, including failed ones.
+// The engine chrome uses one Chrome process for all logins, and runs each login in a separate browser context.
+//
+// When the context is canceled the function stops the test, and returns a report of finished logins.
+func Bench(ctx context.Context, chromeURL string, cnf *BenchConfig) (*BenchReport, error) {
+	debugger := log.DebuggerFromContext(ctx)
+
+	if cnf.Duration < 0 || cnf.Iterations < 0 {
+		return nil, errors.New(errors.KindBenchInvalid, "duration and number of iterations must not be negative")
+	}
+	if cnf.Duration == 0 && cnf.Iterations == 0 {
+		return nil, errors.New(errors.KindBenchInvalid, "duration or number of iterations is missed")
+	}
+	concurrency := cnf.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// The password is requested once for all logins.
+	loginCnf := *cnf.Login
+	if loginCnf.PasswordStdin {
+		password, err := pwdFromStdin()
+		if err != nil {
+			return nil, err
+		}
+		loginCnf.Password, loginCnf.PasswordStdin = password, false
+	}
+
+	if loginCnf.Engine == "" || loginCnf.Engine == EngineChrome {
+		session, err := chrome.NewSession(ctx, chromeURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "connect to chrome")
+		}
+		defer session.Close()
+		ctx = chrome.WithSession(ctx, session)
+	}
+
+	var (
+		mu        sync.Mutex
+		started   int
+		succeeded int
+		latencies = make(map[string][]time.Duration)
+		errKinds  = make(map[string]int)
+		wg        sync.WaitGroup
+	)
+	ctx = WithStepObserver(ctx, func(step string, d time.Duration) {
+		mu.Lock()
+		latencies[step] = append(latencies[step], d)
+		mu.Unlock()
+	})
+	// next reserves the next iteration, and returns false when the test is over.
+	begin := time.Now()
+	next := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return false
+		}
+		if cnf.Iterations > 0 && started >= cnf.Iterations {
+			return false
+		}
+		if cnf.Duration > 0 && time.Since(begin) >= cnf.Duration {
+			return false
+		}
+		started++
+		return true
+	}
+
+	debugger.Debugf("Run the load test with %d concurrent logins\n", concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next() {
+				start := time.Now()
+				_, err := Login(ctx, chromeURL, &loginCnf)
+				d := time.Since(start)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					succeeded++
+					latencies[StepTotal] = append(latencies[StepTotal], d)
+				case ctx.Err() != nil:
+					// A login that is interrupted by the cancellation is not a failure of the OpenID Connect Provider.
+					started--
+				default:
+					debugger.Debugf("The login failed: %s\n", err)
+					errKinds[errors.KindOf(err).String()]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(begin)
+
+	report := &BenchReport{
+		Elapsed:   milliseconds(elapsed),
+		Logins:    started,
+		Succeeded: succeeded,
+		Failed:    started - succeeded,
+		Errors:    errKinds,
+	}
+	if elapsed > 0 {
+		report.Rate = math.Round(float64(started)/elapsed.Seconds()*100) / 100
+	}
+	for _, step := range benchSteps {
+		if ds := latencies[step]; len(ds) > 0 {
+			report.Steps = append(report.Steps, stepStats(step, ds))
+		}
+	}
+	return report, nil
+}
+
+// stepStats returns latencies of a step. Percentiles are computed by the nearest-rank method.
+func stepStats(step string, ds []time.Duration) *StepStats {
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(ds)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return milliseconds(ds[i])
+	}
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return &StepStats{
+		Step:  step,
+		Count: len(ds),
+		Min:   milliseconds(ds[0]),
+		Mean:  milliseconds(sum / time.Duration(len(ds))),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P95:   percentile(95),
+		P99:   percentile(99),
+		Max:   milliseconds(ds[len(ds)-1]),
+	}
+}
+
+// milliseconds returns a duration in milliseconds rounded to microseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d/time.Microsecond) / 1000
+}
+
+// WriteText writes the report in a human-readable format.
+func (r *BenchReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Logins:\t%d (%d succeeded, %d failed)\n", r.Logins, r.Succeeded, r.Failed)
+	fmt.Fprintf(tw, "Elapsed:\t%s\n", time.Duration(r.Elapsed*float64(time.Millisecond)).Round(time.Millisecond))
+	fmt.Fprintf(tw, "Rate:\t%.2f logins/s\n", r.Rate)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Steps) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "step\tcount\tmin\tmean\tp50\tp90\tp95\tp99\tmax\t")
+		for _, s := range r.Steps {
+			fmt.Fprintf(tw, "%s\t%d\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t\n",
+				s.Step, s.Count, s.Min, s.Mean, s.P50, s.P90, s.P95, s.P99, s.Max)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w)
+		kinds := make([]string, 0, len(r.Errors))
+		for kind := range r.Errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "error\tcount")
+		for _, kind := range kinds {
+			fmt.Fprintf(tw, "%s\t%d\n", kind, r.Errors[kind])
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
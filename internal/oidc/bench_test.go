@@ -0,0 +1,176 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestBench(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q}`, issuer, issuer+"/oauth2/auth")
+		case "/oauth2/auth":
+			q := url.Values{}
+			q.Set("state", r.URL.Query().Get("state"))
+			q.Set("nonce", r.URL.Query().Get("nonce"))
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, htmlForm("/handle-auth?"+q.Encode()))
+		case "/handle-auth":
+			// Each third login fails.
+			mu.Lock()
+			attempts++
+			fail := attempts%3 == 0
+			mu.Unlock()
+			if fail {
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprintln(w, htmlFormWithError("/handle-auth", "too many requests"))
+				return
+			}
+			q := r.URL.Query()
+			fragment := url.Values{}
+			fragment.Set("access_token", "access-token")
+			fragment.Set("id_token", testIDToken(q.Get("nonce")))
+			fragment.Set("state", q.Get("state"))
+			http.Redirect(w, r, "http://localhost:9000/auth-callback#"+fragment.Encode(), http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	loginCnf := &LoginConfig{
+		Endpoint:      srv.URL,
+		Engine:        EngineHTTP,
+		ClientID:      "test-client",
+		RedirectURI:   "http://localhost:9000/auth-callback",
+		Scopes:        "openid",
+		Username:      "foo",
+		Password:      "bar",
+		UsernameField: "#user",
+		PasswordField: "#pass",
+		SubmitButton:  "#submit",
+		ErrorMessage:  "#error",
+	}
+
+	testCases := []struct {
+		name    string
+		cnf     *BenchConfig
+		want    *BenchReport
+		wantErr error
+	}{
+		{
+			name:    "limits are missed",
+			cnf:     &BenchConfig{Login: loginCnf, Concurrency: 2},
+			wantErr: errors.New(errors.KindBenchInvalid),
+		},
+		{
+			name:    "negative duration",
+			cnf:     &BenchConfig{Login: loginCnf, Duration: -time.Second, Iterations: 1},
+			wantErr: errors.New(errors.KindBenchInvalid),
+		},
+		{
+			name: "iterations",
+			cnf:  &BenchConfig{Login: loginCnf, Iterations: 6, Concurrency: 2},
+			want: &BenchReport{
+				Logins:    6,
+				Succeeded: 4,
+				Failed:    2,
+				Steps: []*StepStats{
+					{Step: StepNavigate, Count: 6},
+					{Step: StepFormReady, Count: 6},
+					{Step: StepSubmit, Count: 6},
+					{Step: StepCallback, Count: 4},
+					{Step: StepTotal, Count: 4},
+				},
+				Errors: map[string]int{string(errors.KindLoginError): 2},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			attempts = 0
+			mu.Unlock()
+
+			got, err := Bench(context.Background(), "", tc.cnf)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+
+			if got.Logins != tc.want.Logins || got.Succeeded != tc.want.Succeeded || got.Failed != tc.want.Failed {
+				t.Errorf("got %d logins (%d succeeded, %d failed), want %d logins (%d succeeded, %d failed)",
+					got.Logins, got.Succeeded, got.Failed, tc.want.Logins, tc.want.Succeeded, tc.want.Failed)
+			}
+			if !reflect.DeepEqual(got.Errors, tc.want.Errors) {
+				t.Errorf("got errors %v, want %v", got.Errors, tc.want.Errors)
+			}
+			var gotSteps, wantSteps []string
+			for _, s := range got.Steps {
+				gotSteps = append(gotSteps, fmt.Sprintf("%s:%d", s.Step, s.Count))
+				if s.Min > s.P50 || s.P50 > s.P90 || s.P90 > s.P95 || s.P95 > s.P99 || s.P99 > s.Max {
+					t.Errorf("got unordered latencies of the step %q: %+v", s.Step, s)
+				}
+			}
+			for _, s := range tc.want.Steps {
+				wantSteps = append(wantSteps, fmt.Sprintf("%s:%d", s.Step, s.Count))
+			}
+			if !reflect.DeepEqual(gotSteps, wantSteps) {
+				t.Errorf("got steps %v, want %v", gotSteps, wantSteps)
+			}
+
+			var buf bytes.Buffer
+			if err = got.WriteText(&buf); err != nil {
+				t.Fatalf("failed to write the report: %s", err)
+			}
+			for _, s := range append(wantSteps, "login_error") {
+				if name := strings.Split(s, ":")[0]; !strings.Contains(buf.String(), name) {
+					t.Errorf("the text report does not contain %q:\n%s", name, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestStepStats(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i > 0; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	got := stepStats(StepSubmit, ds)
+	want := &StepStats{Step: StepSubmit, Count: 100, Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
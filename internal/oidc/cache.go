@@ -0,0 +1,156 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"time"
+
+	"github.com/i-core/tokget/internal/cache"
+	"github.com/i-core/tokget/internal/jwt"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// LoginCached returns tokens from the token cache while they do not expire within the skew.
+//
+// When the cached tokens are about to expire and contain a refresh token the function refreshes them.
+// Only when there are no cached tokens, or the refresh fails, or the refreshed tokens are about to expire too,
+// for example, when the provider does not issue a new ID token, the function runs the login process.
+// New tokens are stored in the cache.
+//
+// The tokens are cached by the issuer, client's ID, username, scopes and audience of the configuration.
+func LoginCached(ctx context.Context, chromeURL string, cnf *LoginConfig, c *cache.Cache, skew time.Duration) (*LoginData, error) {
+	debugger := log.DebuggerFromContext(ctx)
+
+	issuer := cnf.Endpoint
+	if cnf.Metadata.Issuer != "" {
+		issuer = cnf.Metadata.Issuer
+	}
+	key := cache.NewKey(issuer, cnf.ClientID, cnf.Username, cnf.Scopes, cnf.Audience)
+	entry, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		now := timeNow()
+		if entry.Valid(now, skew) {
+			debugger.Debugf("Use cached tokens that expire at %s\n", entry.Expiry.Format(time.RFC3339))
+			data := cachedLoginData(entry, now)
+			// Cached tokens are checked because of the claim checks can differ from the ones of the login that issued them.
+			if err = checkClaims(data, cnf.Expect); err != nil {
+				return nil, err
+			}
+			return data, nil
+		}
+		if entry.RefreshToken != "" {
+			debugger.Debugln("Cached tokens are about to expire; refresh them")
+			data, err := Refresh(ctx, &RefreshConfig{
+				Endpoint:     cnf.Endpoint,
+				Metadata:     cnf.Metadata,
+				ClientID:     cnf.ClientID,
+				ClientAuth:   cnf.ClientAuth,
+				RefreshToken: entry.RefreshToken,
+			})
+			switch {
+			case err == nil:
+				// A provider can issue no ID token on refresh, so the previous one is kept.
+				if data.IDToken == "" {
+					data.IDToken = entry.IDToken
+				}
+				// The scope is omitted when it is not changed.
+				if data.Scope == "" {
+					data.Scope = entry.Scope
+				}
+				// The kept ID token can be expired, so the refreshed tokens are used only when they are valid.
+				refreshed := newCacheEntry(key, data, now)
+				if refreshed.Valid(now, skew) {
+					if err = c.Put(refreshed); err != nil {
+						return nil, err
+					}
+					if err = checkClaims(data, cnf.Expect); err != nil {
+						return nil, err
+					}
+					return data, nil
+				}
+				debugger.Debugln("Refreshed tokens are about to expire")
+			case ctx.Err() != nil:
+				return nil, err
+			default:
+				debugger.Debugf("Failed to refresh cached tokens: %s\n", err)
+			}
+		}
+	}
+
+	data, err := Login(ctx, chromeURL, cnf)
+	if err != nil {
+		return nil, err
+	}
+	return data, putLoginData(c, key, data)
+}
+
+// putLoginData stores tokens in the token cache.
+func putLoginData(c *cache.Cache, key cache.Key, data *LoginData) error {
+	return c.Put(newCacheEntry(key, data, timeNow()))
+}
+
+// newCacheEntry returns a cache entry of tokens that are issued at a time.
+func newCacheEntry(key cache.Key, data *LoginData, now time.Time) *cache.Entry {
+	e := &cache.Entry{
+		Key:          key,
+		AccessToken:  data.AccessToken,
+		IDToken:      data.IDToken,
+		RefreshToken: data.RefreshToken,
+		Scope:        data.Scope,
+		Expiry:       tokensExpiry(data, now),
+		Updated:      now,
+	}
+	if data.ExpiresIn > 0 {
+		e.AccessTokenExpiry = now.Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	return e
+}
+
+// cachedLoginData returns cached tokens. The lifetime of the access token is the remaining one.
+//
+// The lifetime is computed from the access token's own expiration time, so it does not depend on the ID token.
+func cachedLoginData(e *cache.Entry, now time.Time) *LoginData {
+	data := &LoginData{AccessToken: e.AccessToken, IDToken: e.IDToken, RefreshToken: e.RefreshToken, Scope: e.Scope}
+	if !e.AccessTokenExpiry.IsZero() {
+		data.ExpiresIn = int64(e.AccessTokenExpiry.Sub(now) / time.Second)
+	}
+	return data
+}
+
+// tokensExpiry returns the earliest expiration time of tokens. The expiration time of the access token
+// is taken from expires_in or the claim "exp" of a JWT access token, and the expiration time of the ID token
+// is taken from its claim "exp". If the expiration time is unknown the function returns the zero time.
+func tokensExpiry(data *LoginData, now time.Time) time.Time {
+	var expiry time.Time
+	earliest := func(t time.Time) {
+		if expiry.IsZero() || t.Before(expiry) {
+			expiry = t
+		}
+	}
+	if data.ExpiresIn > 0 {
+		earliest(now.Add(time.Duration(data.ExpiresIn) * time.Second))
+	}
+	for _, raw := range []string{data.AccessToken, data.IDToken} {
+		if raw == "" {
+			continue
+		}
+		// An access token is not always a JWT, so a token that can not be parsed is skipped.
+		tok, err := jwt.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if exp, ok := tok.Int("exp"); ok {
+			earliest(time.Unix(exp, 0))
+		}
+	}
+	return expiry
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/cache"
+)
+
+func TestLoginCached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q, "token_endpoint": %q}`,
+				issuer, issuer+"/oauth2/auth", issuer+"/oauth2/token")
+		case "/oauth2/auth":
+			q := url.Values{}
+			q.Set("state", r.URL.Query().Get("state"))
+			q.Set("nonce", r.URL.Query().Get("nonce"))
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, htmlForm("/handle-auth?"+q.Encode()))
+		case "/handle-auth":
+			q := r.URL.Query()
+			fragment := url.Values{}
+			fragment.Set("access_token", "login-at")
+			fragment.Set("id_token", testIDToken(q.Get("nonce")))
+			fragment.Set("expires_in", "3600")
+			fragment.Set("state", q.Get("state"))
+			http.Redirect(w, r, "http://localhost:9000/auth-callback#"+fragment.Encode(), http.StatusFound)
+		case "/oauth2/token":
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "valid-rt" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintln(w, `{"error": "invalid_grant"}`)
+				return
+			}
+			fmt.Fprintln(w, `{"access_token": "refresh-at", "token_type": "bearer", "expires_in": 3600}`)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	cnf := &LoginConfig{
+		Endpoint:      srv.URL,
+		Engine:        EngineHTTP,
+		ClientID:      "test-client",
+		RedirectURI:   "http://localhost:9000/auth-callback",
+		Scopes:        "openid email",
+		Username:      "foo",
+		Password:      "bar",
+		UsernameField: "#user",
+		PasswordField: "#pass",
+		SubmitButton:  "#submit",
+		ErrorMessage:  "#error",
+	}
+	key := cache.NewKey(srv.URL, "test-client", "foo", "email openid", "")
+
+	testCases := []struct {
+		name   string
+		cached *cache.Entry
+		want   *LoginData
+	}{
+		{
+			name: "no cached tokens",
+			want: &LoginData{AccessToken: "login-at", ExpiresIn: 3600},
+		},
+		{
+			name:   "valid cached tokens",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: "cached-it", Expiry: now.Add(30 * time.Minute), AccessTokenExpiry: now.Add(30 * time.Minute)},
+			want:   &LoginData{AccessToken: "cached-at", IDToken: "cached-it", ExpiresIn: 1800},
+		},
+		{
+			name: "cached ID token expires earlier than access token",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: jwtWithExp(now.Add(10 * time.Minute)),
+				Expiry: now.Add(10 * time.Minute), AccessTokenExpiry: now.Add(30 * time.Minute)},
+			want: &LoginData{AccessToken: "cached-at", IDToken: jwtWithExp(now.Add(10 * time.Minute)), ExpiresIn: 1800},
+		},
+		{
+			name:   "cached tokens of another user",
+			cached: &cache.Entry{Key: cache.NewKey(srv.URL, "test-client", "baz", "openid email", ""), AccessToken: "cached-at", Expiry: now.Add(time.Hour)},
+			want:   &LoginData{AccessToken: "login-at", ExpiresIn: 3600},
+		},
+		{
+			name:   "cached tokens expire within skew",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: "cached-it", Expiry: now.Add(30 * time.Second)},
+			want:   &LoginData{AccessToken: "login-at", ExpiresIn: 3600},
+		},
+		{
+			name:   "refresh expired tokens",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: "cached-it", RefreshToken: "valid-rt", Expiry: now.Add(-time.Minute)},
+			want:   &LoginData{AccessToken: "refresh-at", IDToken: "cached-it", RefreshToken: "valid-rt", ExpiresIn: 3600},
+		},
+		{
+			name:   "refreshed tokens contain expired ID token",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: jwtWithExp(now.Add(-time.Minute)), RefreshToken: "valid-rt", Expiry: now.Add(-time.Minute)},
+			want:   &LoginData{AccessToken: "login-at", ExpiresIn: 3600},
+		},
+		{
+			name:   "refresh fails",
+			cached: &cache.Entry{Key: key, AccessToken: "cached-at", IDToken: "cached-it", RefreshToken: "revoked-rt", Expiry: now.Add(-time.Minute)},
+			want:   &LoginData{AccessToken: "login-at", ExpiresIn: 3600},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tokget")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			c := cache.New(filepath.Join(dir, "tokens.json"), nil)
+			if tc.cached != nil {
+				if err = c.Put(tc.cached); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := LoginCached(context.Background(), "", cnf, c, time.Minute)
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			// An ID token of a login is random because of the nonce.
+			if tc.want.IDToken == "" {
+				got.IDToken = ""
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+
+			entry, err := c.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry == nil || entry.AccessToken != tc.want.AccessToken {
+				t.Fatalf("got cached tokens %+v, want the access token %q", entry, tc.want.AccessToken)
+			}
+			if tc.want.AccessToken != "cached-at" {
+				if want := now.Add(time.Hour); !entry.Expiry.Equal(want) {
+					t.Errorf("got cached expiry %s, want %s", entry.Expiry, want)
+				}
+				if want := now.Add(time.Hour); !entry.AccessTokenExpiry.Equal(want) {
+					t.Errorf("got cached expiry of the access token %s, want %s", entry.AccessTokenExpiry, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokensExpiry(t *testing.T) {
+	now := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name string
+		data *LoginData
+		want time.Time
+	}{
+		{
+			name: "unknown expiry",
+			data: &LoginData{AccessToken: "opaque", IDToken: testIDToken("nonce")},
+		},
+		{
+			name: "expires_in",
+			data: &LoginData{AccessToken: "opaque", ExpiresIn: 300},
+			want: now.Add(5 * time.Minute),
+		},
+		{
+			name: "ID token expires earlier",
+			data: &LoginData{AccessToken: "opaque", IDToken: jwtWithExp(now.Add(time.Minute)), ExpiresIn: 300},
+			want: now.Add(time.Minute),
+		},
+		{
+			name: "JWT access token expires earlier",
+			data: &LoginData{AccessToken: jwtWithExp(now.Add(2 * time.Minute)), IDToken: jwtWithExp(now.Add(time.Hour))},
+			want: now.Add(2 * time.Minute),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tokensExpiry(tc.data, now); !got.Equal(tc.want) {
+				t.Fatalf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// jwtWithExp returns an unsigned JWT that expires at a time.
+func jwtWithExp(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"sub":"foo","exp":%d}`, exp.Unix())))
+	return header + "." + claims + "."
+}
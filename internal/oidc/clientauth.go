@@ -0,0 +1,177 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+// Client authentication methods at the token endpoint.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication.
+const (
+	// AuthNone is used by a public client that does not authenticate at the token endpoint.
+	AuthNone = "none"
+	// AuthClientSecretBasic sends a client's secret by HTTP Basic authentication.
+	AuthClientSecretBasic = "client_secret_basic"
+	// AuthClientSecretPost sends a client's secret in the request's body.
+	AuthClientSecretPost = "client_secret_post"
+	// AuthClientSecretJWT sends a JWT that is signed with a client's secret by HMAC.
+	AuthClientSecretJWT = "client_secret_jwt"
+	// AuthPrivateKeyJWT sends a JWT that is signed with a client's private key.
+	AuthPrivateKeyJWT = "private_key_jwt"
+)
+
+// ClientAuth is a configuration of the client authentication at the token endpoint.
+type ClientAuth struct {
+	Method       string // a client authentication method; when it is empty it is chosen by the defined credentials
+	Secret       string // a client's secret
+	SecretFile   string // a path to a file that contains a client's secret
+	SecretEnv    string // a name of an environment variable that contains a client's secret
+	KeyFile      string // a path to a file that contains a client's private key in PEM or JWK format
+	KeyID        string // a client's key ID; overrides the key ID from a JWK file
+	AssertionAlg string // a JWS algorithm of a client assertion
+}
+
+// method returns the client authentication method.
+//
+// When the method is not specified the function chooses it by the defined credentials.
+func (a *ClientAuth) method() string {
+	if a.Method != "" {
+		return a.Method
+	}
+	switch {
+	case a.KeyFile != "":
+		return AuthPrivateKeyJWT
+	case a.Secret != "" || a.SecretFile != "" || a.SecretEnv != "":
+		return AuthClientSecretBasic
+	}
+	return AuthNone
+}
+
+// validate checks that the client authentication method is supported and its credentials are defined.
+func (a *ClientAuth) validate() error {
+	switch m := a.method(); m {
+	case AuthNone:
+	case AuthClientSecretBasic, AuthClientSecretPost, AuthClientSecretJWT:
+		if a.Secret == "" && a.SecretFile == "" && a.SecretEnv == "" {
+			return errors.New(errors.KindClientSecretMissed, "client's secret is missed (required by %s)", m)
+		}
+	case AuthPrivateKeyJWT:
+		if a.KeyFile == "" {
+			return errors.New(errors.KindClientKeyMissed, "client's private key is missed (required by %s)", m)
+		}
+	default:
+		return errors.New(errors.KindClientAuthInvalid, "client authentication method %q is not supported", m)
+	}
+	return nil
+}
+
+// secret returns a client's secret.
+//
+// The secret is taken from the configuration, from a file or from an environment variable, in the order.
+func (a *ClientAuth) secret() (string, error) {
+	v, err := readSecret(a.Secret, a.SecretFile, a.SecretEnv)
+	if err != nil {
+		return "", errors.Wrap(err, "read client's secret")
+	}
+	if v == "" {
+		if a.Secret == "" && a.SecretFile == "" && a.SecretEnv != "" {
+			return "", errors.New(errors.KindClientSecretMissed, "environment variable %q is empty", a.SecretEnv)
+		}
+		return "", errors.New(errors.KindClientSecretMissed, "client's secret is missed")
+	}
+	return v, nil
+}
+
+// apply adds a client's credentials to a token request's form and headers.
+func (a *ClientAuth) apply(clientID, tokenEndpoint string, form url.Values, header http.Header) error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+	switch a.method() {
+	case AuthNone:
+		form.Set("client_id", clientID)
+	case AuthClientSecretBasic:
+		secret, err := a.secret()
+		if err != nil {
+			return err
+		}
+		// By RFC 6749, section 2.3.1, the credentials are form-encoded before Basic encoding.
+		creds := url.QueryEscape(clientID) + ":" + url.QueryEscape(secret)
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(creds)))
+	case AuthClientSecretPost:
+		secret, err := a.secret()
+		if err != nil {
+			return err
+		}
+		form.Set("client_id", clientID)
+		form.Set("client_secret", secret)
+	case AuthClientSecretJWT, AuthPrivateKeyJWT:
+		assertion, err := a.assertion(clientID, tokenEndpoint)
+		if err != nil {
+			return errors.Wrap(err, "create client assertion")
+		}
+		form.Set("client_id", clientID)
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", assertion)
+	}
+	return nil
+}
+
+// assertion returns a client assertion that is signed with a client's secret or a client's private key.
+func (a *ClientAuth) assertion(clientID, tokenEndpoint string) (string, error) {
+	header := &jwt.Header{Alg: a.AssertionAlg, Typ: "JWT", Kid: a.KeyID}
+	var key interface{}
+	if a.method() == AuthClientSecretJWT {
+		secret, err := a.secret()
+		if err != nil {
+			return "", err
+		}
+		key = []byte(secret)
+		if header.Alg == "" {
+			header.Alg = "HS256"
+		}
+	} else {
+		b, err := ioutil.ReadFile(a.KeyFile)
+		if err != nil {
+			return "", errors.Wrap(err, "read client's private key file")
+		}
+		var kid string
+		if key, kid, err = jwt.ParsePrivateKey(b); err != nil {
+			return "", errors.Wrap(err, "parse client's private key")
+		}
+		if header.Kid == "" {
+			header.Kid = kid
+		}
+		if header.Alg == "" {
+			header.Alg = jwt.DefaultAlg(key)
+		}
+	}
+
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": tokenEndpoint,
+		"jti": jti,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	return jwt.Sign(header, claims, key)
+}
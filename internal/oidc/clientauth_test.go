@@ -0,0 +1,188 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretFile := filepath.Join(dir, "secret")
+	if err = ioutil.WriteFile(secretFile, []byte("file-secret\n"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %s", err)
+	}
+	os.Setenv("TOKGET_TEST_CLIENT_SECRET", "env-secret")
+	defer os.Unsetenv("TOKGET_TEST_CLIENT_SECRET")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if err = ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("failed to write key file: %s", err)
+	}
+
+	const tokenEndpoint = "http://op/token"
+
+	// verifyAssertion checks a client assertion's signature and claims.
+	verifyAssertion := func(t *testing.T, assertion string, verify func(signingInput, sig []byte) bool) {
+		parts := strings.Split(assertion, ".")
+		if len(parts) != 3 {
+			t.Fatalf("got invalid client assertion %q", assertion)
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Fatalf("failed to decode client assertion's signature: %s", err)
+		}
+		if !verify([]byte(parts[0]+"."+parts[1]), sig) {
+			t.Fatalf("client assertion has an invalid signature")
+		}
+		b, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatalf("failed to decode client assertion's claims: %s", err)
+		}
+		var claims map[string]interface{}
+		if err = json.Unmarshal(b, &claims); err != nil {
+			t.Fatalf("failed to parse client assertion's claims: %s", err)
+		}
+		for k, v := range map[string]string{"iss": "test-client", "sub": "test-client", "aud": tokenEndpoint} {
+			if claims[k] != v {
+				t.Fatalf("got claim %q = %v, want %q", k, claims[k], v)
+			}
+		}
+	}
+	verifyHS256 := func(secret string) func(signingInput, sig []byte) bool {
+		return func(signingInput, sig []byte) bool {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(signingInput)
+			return hmac.Equal(mac.Sum(nil), sig)
+		}
+	}
+
+	testCases := []struct {
+		name       string
+		auth       *ClientAuth
+		wantForm   url.Values
+		wantHeader string
+		verify     func(signingInput, sig []byte) bool
+		wantErr    error
+	}{
+		{
+			name:     "public client",
+			auth:     &ClientAuth{},
+			wantForm: url.Values{"client_id": {"test-client"}},
+		},
+		{
+			name:    "unsupported method",
+			auth:    &ClientAuth{Method: "tls_client_auth"},
+			wantErr: errors.New(errors.KindClientAuthInvalid),
+		},
+		{
+			name:    "secret is missed",
+			auth:    &ClientAuth{Method: AuthClientSecretPost},
+			wantErr: errors.New(errors.KindClientSecretMissed),
+		},
+		{
+			name:    "secret's environment variable is empty",
+			auth:    &ClientAuth{SecretEnv: "TOKGET_TEST_CLIENT_SECRET_EMPTY"},
+			wantErr: errors.New(errors.KindClientSecretMissed),
+		},
+		{
+			name:    "private key is missed",
+			auth:    &ClientAuth{Method: AuthPrivateKeyJWT},
+			wantErr: errors.New(errors.KindClientKeyMissed),
+		},
+		{
+			name:       "client_secret_basic",
+			auth:       &ClientAuth{Secret: "s3cr:t"},
+			wantForm:   url.Values{},
+			wantHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("test-client:s3cr%3At")),
+		},
+		{
+			name:     "client_secret_post with a secret from a file",
+			auth:     &ClientAuth{Method: AuthClientSecretPost, SecretFile: secretFile},
+			wantForm: url.Values{"client_id": {"test-client"}, "client_secret": {"file-secret"}},
+		},
+		{
+			name:     "client_secret_post with a secret from an environment variable",
+			auth:     &ClientAuth{Method: AuthClientSecretPost, SecretEnv: "TOKGET_TEST_CLIENT_SECRET"},
+			wantForm: url.Values{"client_id": {"test-client"}, "client_secret": {"env-secret"}},
+		},
+		{
+			name:   "client_secret_jwt",
+			auth:   &ClientAuth{Method: AuthClientSecretJWT, Secret: "secret"},
+			verify: verifyHS256("secret"),
+		},
+		{
+			name: "private_key_jwt",
+			auth: &ClientAuth{KeyFile: keyFile, KeyID: "key-1"},
+			verify: func(signingInput, sig []byte) bool {
+				h := sha256.Sum256(signingInput)
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, h[:], sig) == nil
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			form, header := url.Values{}, http.Header{}
+			err := tc.auth.apply("test-client", tokenEndpoint, form, header)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+
+			if tc.verify != nil {
+				if got := form.Get("client_assertion_type"); got != "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" {
+					t.Fatalf("got client assertion type %q", got)
+				}
+				verifyAssertion(t, form.Get("client_assertion"), tc.verify)
+				return
+			}
+			if !reflect.DeepEqual(form, tc.wantForm) {
+				t.Fatalf("got form %#v, want form %#v", form, tc.wantForm)
+			}
+			if got := header.Get("Authorization"); got != tc.wantHeader {
+				t.Fatalf("got Authorization header %q, want %q", got, tc.wantHeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// isConsentPage returns true when the current page is the consent page.
+//
+// The page is considered the consent page when it contains the approve button or the deny button.
+// When the selectors of both buttons are not specified the function always returns false.
+func isConsentPage(ctx context.Context, cnf *LoginConfig) (bool, error) {
+	for _, sel := range []string{cnf.ConsentApprove, cnf.ConsentDeny} {
+		if sel == "" {
+			continue
+		}
+		has, err := chrome.HasElement(ctx, sel)
+		if err != nil {
+			return false, errors.Wrap(err, "find the consent page's button")
+		}
+		if has {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// submitConsent grants scopes on the consent page and approves the consent, or denies the consent
+// when the configuration requires it. The function waits for loading of the next page.
+//
+// When the configuration defines scopes to grant the function checks only the scope checkboxes
+// whose values match the scopes, and unchecks the rest. Otherwise, the function checks all scope checkboxes.
+func submitConsent(ctx context.Context, cnf *LoginConfig) error {
+	debugger := log.DebuggerFromContext(ctx)
+
+	button := cnf.ConsentApprove
+	if cnf.DenyConsent {
+		button = cnf.ConsentDeny
+	}
+	has, err := chrome.HasElement(ctx, button)
+	if err != nil {
+		return errors.Wrap(err, "find the consent page's button")
+	}
+	if !has {
+		return errors.New(errors.KindConsentButtonInvalid, "the consent page does not contain the button %q", button)
+	}
+
+	if !cnf.DenyConsent && cnf.ConsentScope != "" {
+		granted, err := grantScopes(ctx, cnf.ConsentScope, strings.Fields(cnf.GrantScopes))
+		if err != nil {
+			return err
+		}
+		debugger.Debugf("Grant scopes %q\n", granted)
+	}
+
+	if cnf.DenyConsent {
+		debugger.Debugln("Deny the consent")
+	} else {
+		debugger.Debugln("Approve the consent")
+	}
+	wait := chrome.PageLoadWaiterFunc(ctx, false, 5*time.Second)
+	if err = chromedp.Run(ctx, chromedp.Click(button)); err != nil {
+		return errors.Wrap(err, "submit the consent form")
+	}
+	if err = wait(); err != nil {
+		return errors.Wrap(err, "wait for submiting the consent form")
+	}
+	return nil
+}
+
+// grantScopes sets the state of the scope checkboxes on the consent page, and returns granted scopes.
+//
+// When scopes are empty the function checks all scope checkboxes.
+// The function returns an error when the consent page does not offer one of the scopes.
+func grantScopes(ctx context.Context, sel string, scopes []string) ([]string, error) {
+	// The selector is a JSON string literal, so its characters are escaped as JavaScript expects.
+	selJS, err := json.Marshal(sel)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode scope selector")
+	}
+	// null means all scopes.
+	scopesJS := []byte("null")
+	if len(scopes) > 0 {
+		if scopesJS, err = json.Marshal(scopes); err != nil {
+			return nil, errors.Wrap(err, "encode scopes")
+		}
+	}
+	// The expression returns the values of all scope checkboxes, and checks the boxes of the requested scopes.
+	expr := fmt.Sprintf(`(function(sel, scopes) {
+		var offered = [];
+		document.querySelectorAll(sel).forEach(function(el) {
+			offered.push(el.value);
+			el.checked = scopes === null || scopes.indexOf(el.value) >= 0;
+		});
+		return offered;
+	})(%s, %s)`, selJS, scopesJS)
+	var offered []string
+	if err = chromedp.Run(ctx, chromedp.Evaluate(expr, &offered)); err != nil {
+		return nil, errors.Wrap(err, "grant scopes")
+	}
+	if len(scopes) == 0 {
+		return offered, nil
+	}
+	for _, scope := range scopes {
+		if !contains(offered, scope) {
+			return nil, errors.New(errors.KindConsentScopeInvalid, "the consent page does not offer the scope %q", scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+// timeClaims are claims that contain times and are printed as human-readable times.
+var timeClaims = []string{"exp", "iat", "nbf"}
+
+// DecodedToken is a decoded JSON Web Token.
+type DecodedToken struct {
+	Header map[string]interface{} // the token's JOSE header
+	Claims map[string]interface{} // the token's claims; numbers are decoded as json.Number
+	JWKS   string                 // a JWK Set that verified the token's signature; empty when the signature is not verified
+
+	tok *jwt.Token
+}
+
+// DecodeToken decodes a JSON Web Token. When jwks is defined the token's signature is verified
+// with a key from the JWK Set. The JWK Set is loaded from jwks when it is an URL, or is read from a file.
+func DecodeToken(ctx context.Context, raw, jwks string) (*DecodedToken, error) {
+	tok, err := jwt.Parse(raw)
+	if err != nil {
+		return nil, errors.New(errors.KindTokenInvalid, err, "decode token")
+	}
+	// The type jwt.Header contains only the fields that tokget uses, so the header is decoded again to print it completely.
+	b, err := base64.RawURLEncoding.DecodeString(raw[:strings.Index(raw, ".")])
+	if err != nil {
+		return nil, errors.New(errors.KindTokenInvalid, err, "decode header")
+	}
+	var header map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&header); err != nil {
+		return nil, errors.New(errors.KindTokenInvalid, err, "parse header")
+	}
+
+	decoded := &DecodedToken{Header: header, Claims: tok.Claims, tok: tok}
+	if jwks == "" {
+		return decoded, nil
+	}
+	var set *jwt.JWKS
+	if strings.HasPrefix(jwks, "http://") || strings.HasPrefix(jwks, "https://") {
+		if set, err = loadJWKS(ctx, jwks); err != nil {
+			return nil, err
+		}
+	} else {
+		b, err := ioutil.ReadFile(jwks)
+		if err != nil {
+			return nil, errors.New(errors.KindJWKSFailed, err, "read the JWK Set")
+		}
+		if set, err = jwt.ParseJWKS(b); err != nil {
+			return nil, errors.New(errors.KindJWKSFailed, err, "read the JWK Set")
+		}
+	}
+	if err = verifySignature(tok, set); err != nil {
+		return nil, err
+	}
+	decoded.JWKS = jwks
+	return decoded, nil
+}
+
+// WriteText writes the token's header and claims in indented JSON, and the claims exp, iat and nbf
+// as times in the location of now with the time that is left or passed.
+func (t *DecodedToken) WriteText(w io.Writer, now time.Time) error {
+	for _, part := range []struct {
+		title string
+		v     map[string]interface{}
+	}{{"Header", t.Header}, {"Claims", t.Claims}} {
+		b, err := marshalIndent(part.v)
+		if err != nil {
+			return errors.Wrap(err, "encode token")
+		}
+		fmt.Fprintf(w, "%s:\n%s\n", part.title, b)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	var times bool
+	for _, name := range timeClaims {
+		v, ok := t.tok.Int(name)
+		if !ok {
+			continue
+		}
+		if !times {
+			fmt.Fprintln(tw, "Times:")
+			times = true
+		}
+		at := time.Unix(v, 0).In(now.Location())
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", name, at.Format("2006-01-02 15:04:05 MST"), relativeTime(name, at, now))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	signature := "not verified"
+	if t.JWKS != "" {
+		signature = fmt.Sprintf("verified by the JWK Set %s", t.JWKS)
+	}
+	_, err := fmt.Fprintf(w, "Signature: %s\n", signature)
+	return err
+}
+
+// relativeTime describes a time of a claim relative to now, for example, "expires in 59m30s".
+func relativeTime(claim string, at, now time.Time) string {
+	d := at.Sub(now).Round(time.Second)
+	if claim == "exp" {
+		if d > 0 {
+			return fmt.Sprintf("(expires in %s)", d)
+		}
+		return fmt.Sprintf("(expired %s ago)", -d)
+	}
+	if d > 0 {
+		return fmt.Sprintf("(in %s)", d)
+	}
+	return fmt.Sprintf("(%s ago)", -d)
+}
+
+// marshalIndent encodes a value to indented JSON without escaping HTML characters.
+func marshalIndent(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
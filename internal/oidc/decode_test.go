@@ -0,0 +1,194 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+func TestDecodeToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, err := json.Marshal(jwt.JWKS{Keys: []jwt.JWK{
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "tokget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	if err = ioutil.WriteFile(jwksFile, jwks, 0600); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwks)
+	}))
+	defer srv.Close()
+
+	claims := map[string]interface{}{"sub": "user", "exp": 1556715600, "iat": 1556712000}
+	signed, err := jwt.Sign(&jwt.Header{Alg: "RS256", Kid: "rsa"}, claims, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := jwt.Sign(&jwt.Header{Alg: "RS256", Kid: "rsa"}, claims, foreignKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		token    string
+		jwks     string
+		wantJWKS string
+		wantErr  error
+	}{
+		{
+			name:    "malformed token",
+			token:   "opaque-token",
+			wantErr: errors.New(errors.KindTokenInvalid),
+		},
+		{
+			name:  "without verification",
+			token: foreign,
+		},
+		{
+			name:     "verified by a JWK Set file",
+			token:    signed,
+			jwks:     jwksFile,
+			wantJWKS: jwksFile,
+		},
+		{
+			name:     "verified by a JWK Set URL",
+			token:    signed,
+			jwks:     srv.URL,
+			wantJWKS: srv.URL,
+		},
+		{
+			name:    "signed by a foreign key",
+			token:   foreign,
+			jwks:    jwksFile,
+			wantErr: errors.New(errors.KindSignatureInvalid),
+		},
+		{
+			name:    "unsigned token",
+			token:   testIDToken("nonce"),
+			jwks:    jwksFile,
+			wantErr: errors.New(errors.KindSignatureInvalid),
+		},
+		{
+			name:    "missed JWK Set file",
+			token:   signed,
+			jwks:    filepath.Join(dir, "missed.json"),
+			wantErr: errors.New(errors.KindJWKSFailed),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeToken(context.Background(), tc.token, tc.jwks)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if got.JWKS != tc.wantJWKS {
+				t.Errorf("got verifying JWK Set %q, want %q", got.JWKS, tc.wantJWKS)
+			}
+			if alg := got.Header["alg"]; alg != "RS256" {
+				t.Errorf("got header alg %v, want RS256", alg)
+			}
+			if sub := got.Claims["sub"]; sub != "user" {
+				t.Errorf("got claim sub %v, want user", sub)
+			}
+		})
+	}
+}
+
+func TestDecodedTokenWriteText(t *testing.T) {
+	claims := map[string]interface{}{"sub": "user", "name": "<Foo>", "exp": 1556715600, "iat": 1556712000, "nbf": 1556712600}
+	raw, err := jwt.Sign(&jwt.Header{Alg: "HS256", Typ: "JWT"}, claims, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := DecodeToken(context.Background(), raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2019, 5, 1, 12, 5, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	if err = tok.WriteText(&buf, now); err != nil {
+		t.Fatal(err)
+	}
+	want := `Header:
+{
+  "alg": "HS256",
+  "typ": "JWT"
+}
+Claims:
+{
+  "exp": 1556715600,
+  "iat": 1556712000,
+  "name": "<Foo>",
+  "nbf": 1556712600,
+  "sub": "user"
+}
+Times:
+  exp  2019-05-01 13:00:00 UTC  (expires in 55m0s)
+  iat  2019-05-01 12:00:00 UTC  (5m0s ago)
+  nbf  2019-05-01 12:10:00 UTC  (in 5m0s)
+Signature: not verified
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	tok.JWKS = "jwks.json"
+	if err = tok.WriteText(&buf, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"(expired 1h5m0s ago)", "Signature: verified by the JWK Set jwks.json\n"} {
+		if !bytes.Contains(buf.Bytes(), []byte(s)) {
+			t.Errorf("the output does not contain %q:\n%s", s, buf.String())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// ProviderMetadata is an OpenID Connect Provider's metadata.
+//
+// See https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+	RevocationEndpoint    string `json:"revocation_endpoint"`
+}
+
+// endpoint is a name of a metadata's field that contains an endpoint's URL.
+type endpoint string
+
+const (
+	endpointAuthorization endpoint = "authorization_endpoint"
+	endpointToken         endpoint = "token_endpoint"
+	endpointUserinfo      endpoint = "userinfo_endpoint"
+	endpointJWKS          endpoint = "jwks_uri"
+	endpointEndSession    endpoint = "end_session_endpoint"
+	endpointIntrospection endpoint = "introspection_endpoint"
+	endpointRevocation    endpoint = "revocation_endpoint"
+)
+
+// get returns the URL of an endpoint.
+func (m *ProviderMetadata) get(ep endpoint) string {
+	switch ep {
+	case endpointAuthorization:
+		return m.AuthorizationEndpoint
+	case endpointToken:
+		return m.TokenEndpoint
+	case endpointUserinfo:
+		return m.UserinfoEndpoint
+	case endpointJWKS:
+		return m.JWKSURI
+	case endpointEndSession:
+		return m.EndSessionEndpoint
+	case endpointIntrospection:
+		return m.IntrospectionEndpoint
+	case endpointRevocation:
+		return m.RevocationEndpoint
+	}
+	return ""
+}
+
+// override returns a copy of the metadata in which fields are replaced with non-empty fields of overrides.
+func (m ProviderMetadata) override(overrides *ProviderMetadata) *ProviderMetadata {
+	if overrides == nil {
+		return &m
+	}
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&m.Issuer, overrides.Issuer)
+	set(&m.AuthorizationEndpoint, overrides.AuthorizationEndpoint)
+	set(&m.TokenEndpoint, overrides.TokenEndpoint)
+	set(&m.UserinfoEndpoint, overrides.UserinfoEndpoint)
+	set(&m.JWKSURI, overrides.JWKSURI)
+	set(&m.EndSessionEndpoint, overrides.EndSessionEndpoint)
+	set(&m.IntrospectionEndpoint, overrides.IntrospectionEndpoint)
+	set(&m.RevocationEndpoint, overrides.RevocationEndpoint)
+	return &m
+}
+
+// discoveryCache keeps loaded discovery documents during the program's run.
+var discoveryCache = struct {
+	sync.Mutex
+	docs map[string]*ProviderMetadata
+}{docs: make(map[string]*ProviderMetadata)}
+
+// discover returns the metadata of an OpenID Connect Provider with the specified issuer.
+//
+// The function applies overrides to the metadata and checks that the metadata contains all required endpoints.
+// When overrides contain all required endpoints the function doesn't load the discovery document at all,
+// so a provider that doesn't support OpenID Connect Discovery can be used too.
+//
+// A loaded discovery document is cached for the program's run.
+func discover(ctx context.Context, issuer string, overrides *ProviderMetadata, required ...endpoint) (*ProviderMetadata, error) {
+	meta := (&ProviderMetadata{Issuer: issuer}).override(overrides)
+	if hasEndpoints(meta, required) {
+		return meta, nil
+	}
+
+	doc, err := loadDiscoveryDocument(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	meta = doc.override(overrides)
+	for _, ep := range required {
+		if meta.get(ep) == "" {
+			return nil, errors.New(errors.KindProviderEndpointMissed, "OpenID Connect Provider does not define %s", string(ep))
+		}
+	}
+	return meta, nil
+}
+
+func hasEndpoints(meta *ProviderMetadata, eps []endpoint) bool {
+	for _, ep := range eps {
+		if meta.get(ep) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// loadDiscoveryDocument loads the discovery document from "<issuer>/.well-known/openid-configuration".
+func loadDiscoveryDocument(ctx context.Context, issuer string) (*ProviderMetadata, error) {
+	discoveryCache.Lock()
+	defer discoveryCache.Unlock()
+
+	if doc, ok := discoveryCache.docs[issuer]; ok {
+		return doc, nil
+	}
+
+	// By the specification the path "/.well-known/openid-configuration" is appended to the issuer,
+	// so the issuer's path is kept (for example, Keycloak's issuer is "https://host/auth/realms/<realm>").
+	docURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Load the discovery document %q\n", docURL)
+
+	b, err := fetch(ctx, docURL, nil)
+	if err != nil {
+		return nil, errors.New(errors.KindDiscoveryFailed, err, "load the discovery document")
+	}
+	doc := &ProviderMetadata{}
+	if err = json.Unmarshal(b, doc); err != nil {
+		return nil, errors.New(errors.KindDiscoveryFailed, err, "parse the discovery document")
+	}
+	debugger.Debugf("The discovery document is loaded:\n%s\n", string(b))
+
+	discoveryCache.docs[issuer] = doc
+	return doc, nil
+}
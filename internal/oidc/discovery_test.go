@@ -0,0 +1,134 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestDiscover(t *testing.T) {
+	testCases := []struct {
+		name      string
+		doc       string
+		status    int
+		overrides *ProviderMetadata
+		required  []endpoint
+		want      *ProviderMetadata
+		wantLoads int
+		wantErr   error
+	}{
+		{
+			name:      "discovery document is not found",
+			status:    http.StatusNotFound,
+			required:  []endpoint{endpointAuthorization},
+			wantLoads: 1,
+			wantErr:   errors.New(errors.KindDiscoveryFailed),
+		},
+		{
+			name:      "invalid discovery document",
+			doc:       `{"authorization_endpoint": 1}`,
+			status:    http.StatusOK,
+			required:  []endpoint{endpointAuthorization},
+			wantLoads: 1,
+			wantErr:   errors.New(errors.KindDiscoveryFailed),
+		},
+		{
+			name:      "required endpoint is missed",
+			doc:       `{"issuer": "http://issuer", "authorization_endpoint": "http://issuer/auth"}`,
+			status:    http.StatusOK,
+			required:  []endpoint{endpointAuthorization, endpointEndSession},
+			wantLoads: 1,
+			wantErr:   errors.New(errors.KindProviderEndpointMissed),
+		},
+		{
+			name:      "required endpoints are overridden",
+			status:    http.StatusNotFound,
+			overrides: &ProviderMetadata{AuthorizationEndpoint: "http://other/auth"},
+			required:  []endpoint{endpointAuthorization},
+			want:      &ProviderMetadata{AuthorizationEndpoint: "http://other/auth"},
+			wantLoads: 0,
+		},
+		{
+			name: "happy path",
+			doc: `{
+				"issuer": "http://issuer",
+				"authorization_endpoint": "http://issuer/auth",
+				"token_endpoint": "http://issuer/token",
+				"end_session_endpoint": "http://issuer/logout"
+			}`,
+			status:    http.StatusOK,
+			overrides: &ProviderMetadata{TokenEndpoint: "http://other/token"},
+			required:  []endpoint{endpointAuthorization, endpointToken},
+			want: &ProviderMetadata{
+				Issuer:                "http://issuer",
+				AuthorizationEndpoint: "http://issuer/auth",
+				TokenEndpoint:         "http://other/token",
+				EndSessionEndpoint:    "http://issuer/logout",
+			},
+			wantLoads: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var loads int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/realm/.well-known/openid-configuration" {
+					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+					return
+				}
+				loads++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				fmt.Fprintln(w, tc.doc)
+			}))
+			defer srv.Close()
+
+			issuer := srv.URL + "/realm"
+			if tc.want != nil && tc.want.Issuer == "" {
+				tc.want.Issuer = issuer
+			}
+
+			// Discover twice to check that the discovery document is cached.
+			var (
+				got *ProviderMetadata
+				err error
+			)
+			for i := 0; i < 2; i++ {
+				got, err = discover(context.Background(), issuer, tc.overrides, tc.required...)
+				if tc.wantErr != nil {
+					break
+				}
+			}
+
+			if loads != tc.wantLoads {
+				t.Errorf("got %d loads of the discovery document, want %d", loads, tc.wantLoads)
+			}
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+// Tokens which claims are checked by claim checks.
+const (
+	// TokenID is the ID token.
+	TokenID = "id"
+	// TokenAccess is the access token. It is checked only when it is a JWT.
+	TokenAccess = "access"
+)
+
+// Operators of claim checks.
+const (
+	// ExpectEquals expects that a claim equals to a value.
+	ExpectEquals = "equals"
+	// ExpectContains expects that a claim is an array that contains a value,
+	// or a string of space-separated values that contains a value.
+	ExpectContains = "contains"
+	// ExpectScope expects that the claim "scope" (or "scp") contains a value.
+	// When the access token is not a JWT or does not contain the claim, the scopes that the provider returns
+	// with the tokens (the parameter "scope") are checked.
+	ExpectScope = "scope"
+)
+
+// ClaimCheck checks that a token's claim has an expected value. The login fails when a token does not match it.
+type ClaimCheck struct {
+	Token string // a token: TokenID (by default) or TokenAccess
+	Path  string // a claim's path; nested claims are separated by dots, for example, realm_access.roles
+	Op    string // an operator: ExpectEquals (by default), ExpectContains or ExpectScope
+	Value string // an expected value
+}
+
+// String returns the check in a human-readable format.
+func (e *ClaimCheck) String() string {
+	switch e.Op {
+	case ExpectContains:
+		return fmt.Sprintf("%s %s contains %q", tokenTitle(e.Token), e.Path, e.Value)
+	case ExpectScope:
+		return fmt.Sprintf("%s scope contains %q", tokenTitle(e.Token), e.Value)
+	}
+	return fmt.Sprintf("%s %s = %q", tokenTitle(e.Token), e.Path, e.Value)
+}
+
+// checkClaims checks the tokens' claims.
+// The returned error lists all failed checks with actual values of the claims.
+func checkClaims(data *LoginData, expect []*ClaimCheck) error {
+	if len(expect) == 0 {
+		return nil
+	}
+	tokens := make(map[string]*jwt.Token)
+	var diff []string
+	for _, e := range expect {
+		name := e.Token
+		if name == "" {
+			name = TokenID
+		}
+		tok, ok := tokens[name]
+		if !ok {
+			raw := data.IDToken
+			if name == TokenAccess {
+				raw = data.AccessToken
+			}
+			// A token that is not a JWT is kept as nil to report it for each check.
+			tok, _ = jwt.Parse(raw)
+			tokens[name] = tok
+		}
+		if e.Op == ExpectScope && data.Scope != "" && (tok == nil || !hasScopeClaim(tok.Claims)) {
+			if !contains(strings.Fields(data.Scope), e.Value) {
+				diff = append(diff, fmt.Sprintf("%s: got the granted scope %q", e, data.Scope))
+			}
+			continue
+		}
+		if tok == nil {
+			diff = append(diff, fmt.Sprintf("%s: the %s is not a JWT", e, tokenTitle(name)))
+			continue
+		}
+		if msg := matchClaim(tok.Claims, e); msg != "" {
+			diff = append(diff, fmt.Sprintf("%s: %s", e, msg))
+		}
+	}
+	if len(diff) != 0 {
+		return errors.New(errors.KindClaimMismatch, "tokens do not match expected claims:\n  %s", strings.Join(diff, "\n  "))
+	}
+	return nil
+}
+
+// matchClaim checks a claim. The function returns a description of a mismatch,
+// or an empty string when the claim matches.
+func matchClaim(claims map[string]interface{}, e *ClaimCheck) string {
+	path := e.Path
+	if e.Op == ExpectScope {
+		path = "scope"
+		if _, ok := claims[path]; !ok {
+			path = "scp"
+		}
+	}
+	v, ok := lookupClaim(claims, path)
+	if !ok {
+		return "the claim is missed"
+	}
+	switch e.Op {
+	case ExpectContains, ExpectScope:
+		var values []interface{}
+		switch v := v.(type) {
+		case []interface{}:
+			values = v
+		case string:
+			for _, s := range strings.Fields(v) {
+				values = append(values, s)
+			}
+		default:
+			return fmt.Sprintf("got %s, it is neither an array nor a string", claimJSON(v))
+		}
+		for _, item := range values {
+			if claimString(item) == e.Value {
+				return ""
+			}
+		}
+	default:
+		if claimString(v) == e.Value {
+			return ""
+		}
+	}
+	return fmt.Sprintf("got %s", claimJSON(v))
+}
+
+// hasScopeClaim returns true when claims contain the claim "scope" or "scp".
+func hasScopeClaim(claims map[string]interface{}) bool {
+	_, scope := claims["scope"]
+	_, scp := claims["scp"]
+	return scope || scp
+}
+
+// lookupClaim returns a claim by a path. Nested claims are separated by dots.
+// A claim which name contains dots (for example, a namespaced claim "https://example.com/roles") is found as well,
+// because of the longest matching name is tried first at each level.
+func lookupClaim(claims map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := claims[path]; ok {
+		return v, true
+	}
+	for i := strings.LastIndex(path, "."); i > 0; i = strings.LastIndex(path[:i], ".") {
+		nested, ok := claims[path[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := lookupClaim(nested, path[i+1:]); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// claimString returns a claim's value that is compared to an expected value.
+// A string is compared as is, and other values are compared in JSON format, for example, true or 42.
+func claimString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return claimJSON(v)
+}
+
+// claimJSON returns a claim's value in JSON format.
+func claimJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// tokenTitle returns a human-readable name of a token.
+func tokenTitle(token string) string {
+	if token == TokenAccess {
+		return "access token"
+	}
+	return "ID token"
+}
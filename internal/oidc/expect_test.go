@@ -0,0 +1,224 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestCheckClaims(t *testing.T) {
+	token := func(claims string) string {
+		enc := base64.RawURLEncoding
+		return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + "."
+	}
+	data := &LoginData{
+		IDToken: token(`{
+			"sub": "foo",
+			"email_verified": true,
+			"age": 42,
+			"groups": ["dev", "admin"],
+			"realm_access": {"roles": ["user"]},
+			"https://example.com/roles": ["auditor"]
+		}`),
+		AccessToken: token(`{"scope": "openid email api:read"}`),
+	}
+
+	testCases := []struct {
+		name   string
+		data   *LoginData
+		checks []*ClaimCheck
+		want   []string // lines of the error's message
+	}{
+		{
+			name: "no checks",
+		},
+		{
+			name: "all claims match",
+			checks: []*ClaimCheck{
+				{Path: "sub", Value: "foo"},
+				{Path: "email_verified", Value: "true"},
+				{Path: "age", Value: "42"},
+				{Path: "groups", Op: ExpectContains, Value: "admin"},
+				{Path: "realm_access.roles", Op: ExpectContains, Value: "user"},
+				{Path: "https://example.com/roles", Op: ExpectContains, Value: "auditor"},
+				{Token: TokenAccess, Path: "scope", Op: ExpectContains, Value: "email"},
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:read"},
+			},
+		},
+		{
+			name: "scope in the claim scp",
+			data: &LoginData{AccessToken: token(`{"scp": ["api:read", "api:write"]}`)},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:write"},
+			},
+		},
+		{
+			name: "mismatches",
+			checks: []*ClaimCheck{
+				{Path: "sub", Value: "foo"},
+				{Path: "sub", Value: "bar"},
+				{Path: "email_verified", Value: "false"},
+				{Path: "groups", Op: ExpectContains, Value: "ops"},
+				{Path: "realm_access.roles", Op: ExpectContains, Value: "admin"},
+				{Path: "email", Value: "foo@example.com"},
+				{Path: "age", Op: ExpectContains, Value: "4"},
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:write"},
+			},
+			want: []string{
+				"tokens do not match expected claims:",
+				`  ID token sub = "bar": got "foo"`,
+				`  ID token email_verified = "false": got true`,
+				`  ID token groups contains "ops": got ["dev","admin"]`,
+				`  ID token realm_access.roles contains "admin": got ["user"]`,
+				`  ID token email = "foo@example.com": the claim is missed`,
+				`  ID token age contains "4": got 42, it is neither an array nor a string`,
+				`  access token scope contains "api:write": got "openid email api:read"`,
+			},
+		},
+		{
+			name: "opaque access token",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: "opaque"},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api"},
+				{Token: TokenAccess, Path: "aud", Value: "api"},
+			},
+			want: []string{
+				"tokens do not match expected claims:",
+				`  access token scope contains "api": the access token is not a JWT`,
+				`  access token aud = "api": the access token is not a JWT`,
+			},
+		},
+		{
+			name: "granted scope of opaque access token",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: "opaque", Scope: "openid api:read"},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:read"},
+			},
+		},
+		{
+			name: "granted scope of JWT access token without scope claim",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: token(`{"sub": "foo"}`), Scope: "openid api:read"},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:read"},
+			},
+		},
+		{
+			name: "granted scope mismatch",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: "opaque", Scope: "openid email"},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:read"},
+				{Token: TokenAccess, Path: "aud", Value: "api"},
+			},
+			want: []string{
+				"tokens do not match expected claims:",
+				`  access token scope contains "api:read": got the granted scope "openid email"`,
+				`  access token aud = "api": the access token is not a JWT`,
+			},
+		},
+		{
+			name: "scope claim takes precedence over granted scope",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: data.AccessToken, Scope: "api:write"},
+			checks: []*ClaimCheck{
+				{Token: TokenAccess, Op: ExpectScope, Value: "api:read"},
+			},
+		},
+		{
+			name: "ID token and access token checks",
+			data: &LoginData{IDToken: data.IDToken, AccessToken: token(`{"aud": "api"}`)},
+			checks: []*ClaimCheck{
+				{Token: TokenID, Path: "sub", Value: "foo"},
+				{Token: TokenAccess, Path: "aud", Value: "api"},
+				{Token: TokenAccess, Path: "sub", Value: "foo"},
+			},
+			want: []string{
+				"tokens do not match expected claims:",
+				`  access token sub = "foo": the claim is missed`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.data
+			if d == nil {
+				d = data
+			}
+			err := checkClaims(d, tc.checks)
+
+			if tc.want != nil {
+				wantErr := errors.New(errors.KindClaimMismatch)
+				if !errors.Match(err, wantErr) {
+					t.Fatalf("\ngot error:\n\t%v\nwant error:\n\t%s", err, wantErr)
+				}
+				if got, want := err.Error(), strings.Join(tc.want, "\n"); got != want {
+					t.Fatalf("\ngot message:\n%s\nwant message:\n%s", got, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+		})
+	}
+}
+
+func TestLoginWithClaimChecks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q}`, issuer, issuer+"/oauth2/auth")
+		case "/oauth2/auth":
+			q := url.Values{}
+			q.Set("state", r.URL.Query().Get("state"))
+			q.Set("nonce", r.URL.Query().Get("nonce"))
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, htmlForm("/handle-auth?"+q.Encode()))
+		case "/handle-auth":
+			q := r.URL.Query()
+			fragment := url.Values{}
+			fragment.Set("access_token", "opaque")
+			fragment.Set("id_token", testIDToken(q.Get("nonce")))
+			fragment.Set("state", q.Get("state"))
+			http.Redirect(w, r, "http://localhost:9000/auth-callback#"+fragment.Encode(), http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	cnf := &LoginConfig{
+		Endpoint:      srv.URL,
+		Engine:        EngineHTTP,
+		ClientID:      "test-client",
+		RedirectURI:   "http://localhost:9000/auth-callback",
+		Scopes:        "openid",
+		Username:      "foo",
+		Password:      "bar",
+		UsernameField: "#user",
+		PasswordField: "#pass",
+		SubmitButton:  "#submit",
+		ErrorMessage:  "#error",
+		Expect:        []*ClaimCheck{{Path: "sub", Value: "foo"}},
+	}
+	if _, err := Login(context.Background(), "", cnf); err != nil {
+		t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+	}
+
+	cnf.Expect = append(cnf.Expect, &ClaimCheck{Path: "sub", Value: "bar"})
+	wantErr := errors.New(errors.KindClaimMismatch)
+	if _, err := Login(context.Background(), "", cnf); !errors.Match(err, wantErr) {
+		t.Fatalf("\ngot error:\n\t%v\nwant error:\n\t%s", err, wantErr)
+	}
+}
@@ -0,0 +1,437 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/htmlform"
+	"github.com/i-core/tokget/internal/log"
+	"github.com/i-core/tokget/internal/totp"
+)
+
+// Engines that run the login process.
+const (
+	// EngineChrome runs the login process in Google Chrome. It supports login pages that are built by JavaScript.
+	EngineChrome = "chrome"
+	// EngineHTTP runs the login process by plain HTTP requests. It supports login pages that are plain HTML forms.
+	EngineHTTP = "http"
+)
+
+// maxRedirects is the maximum number of redirects that the engine http follows after a request.
+const maxRedirects = 10
+
+// httpBrowser loads pages and submits forms by plain HTTP requests. It keeps cookies between requests
+// and remembers navigation requests like chrome.NavHistory.
+type httpBrowser struct {
+	client      *http.Client
+	redirectURI *url.URL
+	history     []string
+}
+
+// newHTTPBrowser returns a new httpBrowser. The browser does not follow redirects to the client's redirect URI
+// because of the client is usually not available.
+func newHTTPBrowser(redirectURI string) (*httpBrowser, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cookie jar")
+	}
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse client's redirect uri")
+	}
+	client := &http.Client{
+		Jar:     jar,
+		Timeout: 10 * time.Second,
+		// Redirects are followed manually to remember them in the history.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &httpBrowser{client: client, redirectURI: u}, nil
+}
+
+// last returns the last navigation request.
+func (b *httpBrowser) last() string {
+	if len(b.history) == 0 {
+		return ""
+	}
+	return b.history[len(b.history)-1]
+}
+
+// navigate sends a GET request to an URL, and returns the loaded page.
+func (b *httpBrowser) navigate(ctx context.Context, u string) (*htmlform.Page, error) {
+	r, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+	return b.do(ctx, r.WithContext(ctx))
+}
+
+// submit submits a form by a button, and returns the loaded page.
+func (b *httpBrowser) submit(ctx context.Context, page *htmlform.Page, button string) (*htmlform.Page, error) {
+	form, err := page.Form(button)
+	if err != nil {
+		return nil, err
+	}
+	if form == nil {
+		return nil, errors.New("the page does not contain a form with the button %q", button)
+	}
+	return b.submitForm(ctx, form)
+}
+
+// submitForm submits a form, and returns the loaded page.
+func (b *httpBrowser) submitForm(ctx context.Context, form *htmlform.Form) (*htmlform.Page, error) {
+	r, err := form.Request(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return b.do(ctx, r)
+}
+
+// do sends a request, follows redirects, and returns the loaded page.
+//
+// The function returns nil when a redirect leads to the client's redirect URI, or to an unavailable resource
+// with an authentication response (a URL with the parameter "state"). In this case the last navigation request
+// contains the authentication callback. Other failed requests are errors of the provider.
+func (b *httpBrowser) do(ctx context.Context, r *http.Request) (*htmlform.Page, error) {
+	debugger := log.DebuggerFromContext(ctx)
+	for i := 0; ; i++ {
+		b.history = append(b.history, r.URL.String())
+		debugger.Debugf("request %s %s\n", r.Method, r.URL.String())
+		resp, err := b.client.Do(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// The provider can redirect to a client's URL that is not the configured redirect URI.
+			// Chrome remembers such a navigation request too, so tokens are extracted from it.
+			if i > 0 && isAuthResponse(r.URL) {
+				debugger.Debugf("The resource is unavailable: %s\n", err)
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, "send request")
+		}
+
+		loc := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || loc == "" {
+			defer resp.Body.Close()
+			return htmlform.Parse(resp.Request.URL, resp.Body)
+		}
+		resp.Body.Close()
+
+		if i == maxRedirects {
+			return nil, errors.New("stopped after %d redirects", maxRedirects)
+		}
+		ref, err := url.Parse(loc)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse redirect location")
+		}
+		next := r.URL.ResolveReference(ref)
+		if next.Scheme == b.redirectURI.Scheme && next.Host == b.redirectURI.Host && next.Path == b.redirectURI.Path {
+			b.history = append(b.history, next.String())
+			debugger.Debugf("redirect to the client's redirect uri %s\n", next.String())
+			return nil, nil
+		}
+		if r, err = http.NewRequest(http.MethodGet, next.String(), nil); err != nil {
+			return nil, errors.Wrap(err, "create request")
+		}
+		r = r.WithContext(ctx)
+	}
+}
+
+// isAuthResponse returns true when an URL contains the parameter "state" in its query or fragment.
+// The parameter is sent in every authentication request, so the provider returns it in every authentication response.
+func isAuthResponse(u *url.URL) bool {
+	if u.Query().Get("state") != "" {
+		return true
+	}
+	fragment, err := url.ParseQuery(u.Fragment)
+	return err == nil && fragment.Get("state") != ""
+}
+
+// loginHTTP runs the login process by the engine http.
+//
+// The function loads the login page, fills and submits the login form, passes the second factor
+// and the consent page, and extracts tokens from the last redirect.
+func loginHTTP(ctx context.Context, cnf *LoginConfig, meta *ProviderMetadata, authReq *authRequest, password string) (*LoginData, error) {
+	debugger := log.DebuggerFromContext(ctx)
+	timer := stepTimerFromContext(ctx)
+
+	browser, err := newHTTPBrowser(cnf.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	loginStartURL, err := buildLoginURL(meta.AuthorizationEndpoint, authReq)
+	if err != nil {
+		return nil, err
+	}
+	debugger.Debugf("Load the login page %q\n", loginStartURL)
+	timer.start()
+	page, err := browser.navigate(ctx, loginStartURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "load the login page")
+	}
+	timer.finish(StepNavigate)
+	if err = extractOIDCError(browser.last()); err != nil {
+		return nil, err
+	}
+
+	if page != nil {
+		debugger.Debugf("The login page is loaded:\n\n%s\n\n", page.HTML())
+		next, err := submitLoginForm(ctx, browser, page, cnf, password)
+		if err != nil {
+			// The OpenID Connect Provider can show an error instead of the expected page,
+			// for example, about an unknown user on the username page of the multi-step login.
+			if oidcErr := extractOIDCError(browser.last()); oidcErr != nil {
+				return nil, oidcErr
+			}
+			if loginErr := pageLoginError(next, cnf.ErrorMessage); loginErr != nil {
+				return nil, loginErr
+			}
+			return nil, err
+		}
+		timer.finish(StepSubmit)
+		page = next
+	}
+
+	if page != nil && cnf.TOTP.enabled() {
+		if page, err = passTOTPHTTP(ctx, browser, page, &cnf.TOTP, cnf.ErrorMessage); err != nil {
+			return nil, err
+		}
+	}
+
+	if page != nil && (cnf.ConsentApprove != "" || cnf.ConsentDeny != "") {
+		if page, err = submitConsentHTTP(ctx, browser, page, cnf); err != nil {
+			return nil, err
+		}
+	}
+
+	postLoginURL := browser.last()
+	loginData, err := extractLoginData(ctx, postLoginURL, meta, authReq)
+	if err != nil {
+		return nil, err
+	}
+	if loginData != nil {
+		timer.finish(StepCallback)
+		return loginData, nil
+	}
+
+	debugger.Debugln("Failed to authenticate the user")
+	if err = extractOIDCError(postLoginURL); err != nil {
+		return nil, err
+	}
+	if page == nil {
+		return nil, errors.New(errors.KindLoginError, "unexpected redirect to %q", postLoginURL)
+	}
+	if err = pageLoginError(page, cnf.ErrorMessage); err != nil {
+		return nil, err
+	}
+	return nil, errors.New(errors.KindLoginError, "unexpected error page %q\n%s", postLoginURL, page.HTML())
+}
+
+// submitLoginForm fills the username and password, and submits the login form.
+// When the configuration defines the username page's submit button the username and password
+// are submitted on separate pages.
+//
+// The function returns the last loaded page even if it fails to find a field on that page.
+func submitLoginForm(ctx context.Context, browser *httpBrowser, page *htmlform.Page, cnf *LoginConfig, password string) (*htmlform.Page, error) {
+	debugger := log.DebuggerFromContext(ctx)
+	expect := func(page *htmlform.Page, name, sel string, kind errors.Kind) error {
+		has, err := page.HasElement(sel)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return errors.New(kind, "the login form does not contains %s", name)
+		}
+		return nil
+	}
+
+	if err := expect(page, "the username field", cnf.UsernameField, errors.KindUsernameFieldInvalid); err != nil {
+		return page, err
+	}
+	if cnf.UsernameSubmit != "" {
+		if err := expect(page, "the submit button", cnf.UsernameSubmit, errors.KindSubmitButtonInvalid); err != nil {
+			return page, err
+		}
+		form, err := page.Form(cnf.UsernameSubmit)
+		if err != nil {
+			return page, err
+		}
+		if form == nil {
+			return page, errors.New(errors.KindSubmitButtonInvalid, "the submit button does not belong to a form")
+		}
+		stepTimerFromContext(ctx).finish(StepFormReady)
+		if err = form.Fill(cnf.UsernameField, cnf.Username); err != nil {
+			return page, errors.Wrap(err, "fill the username field")
+		}
+		debugger.Debugln("Submit the username")
+		if page, err = browser.submitForm(ctx, form); err != nil || page == nil {
+			return page, err
+		}
+	}
+
+	if err := expect(page, "the password field", cnf.PasswordField, errors.KindPasswordFieldInvalid); err != nil {
+		return page, err
+	}
+	if err := expect(page, "the submit button", cnf.SubmitButton, errors.KindSubmitButtonInvalid); err != nil {
+		return page, err
+	}
+	form, err := page.Form(cnf.SubmitButton)
+	if err != nil {
+		return page, err
+	}
+	if form == nil {
+		return page, errors.New(errors.KindSubmitButtonInvalid, "the submit button does not belong to a form")
+	}
+	stepTimerFromContext(ctx).finish(StepFormReady)
+	if cnf.UsernameSubmit == "" {
+		if err = form.Fill(cnf.UsernameField, cnf.Username); err != nil {
+			return page, errors.Wrap(err, "fill the username field")
+		}
+	}
+	if err = form.Fill(cnf.PasswordField, password); err != nil {
+		return page, errors.Wrap(err, "fill the password field")
+	}
+	debugger.Debugln("Submit the login form")
+	return browser.submitForm(ctx, form)
+}
+
+// passTOTPHTTP fills a one-time password when the page is the one-time password page, and submits it.
+//
+// Unlike the engine chrome, the function does not retry a rejected code. Instead, it waits for the next time step
+// before submitting when the current time step is about to end.
+func passTOTPHTTP(ctx context.Context, browser *httpBrowser, page *htmlform.Page, t *TOTP, errorMessage string) (*htmlform.Page, error) {
+	debugger := log.DebuggerFromContext(ctx)
+
+	has, err := page.HasElement(t.Field)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		debugger.Debugln("The one-time password page is not shown")
+		return page, nil
+	}
+	secret, err := t.secret()
+	if err != nil {
+		return nil, err
+	}
+	if remaining := totp.Remaining(timeNow()); remaining <= otpBoundary {
+		debugger.Debugln("The TOTP time step is about to end; wait for the next one")
+		select {
+		case <-time.After(remaining):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	form, err := page.Form(t.Submit)
+	if err != nil {
+		return nil, err
+	}
+	if form == nil {
+		return nil, errors.New(errors.KindOTPSubmitMissed, "the one-time password page does not contain the submit button %q", t.Submit)
+	}
+	if err = form.Fill(t.Field, totp.Code(secret, totp.Counter(timeNow()))); err != nil {
+		return nil, errors.Wrap(err, "fill the one-time password field")
+	}
+	debugger.Debugln("Submit the one-time password")
+	if page, err = browser.submitForm(ctx, form); err != nil || page == nil {
+		return page, err
+	}
+	if has, err = page.HasElement(t.Field); err != nil || !has {
+		return page, err
+	}
+	if err = pageLoginError(page, errorMessage); err != nil {
+		return nil, errors.New(errors.KindOTPRejected, "the one-time password is rejected: %s", err.Error())
+	}
+	return nil, errors.New(errors.KindOTPRejected, "the one-time password is rejected")
+}
+
+// submitConsentHTTP approves or denies the consent when the page is the consent page.
+// The function returns the page as is when it is not the consent page.
+//
+// See submitConsent for details.
+func submitConsentHTTP(ctx context.Context, browser *httpBrowser, page *htmlform.Page, cnf *LoginConfig) (*htmlform.Page, error) {
+	debugger := log.DebuggerFromContext(ctx)
+
+	var consent bool
+	for _, sel := range []string{cnf.ConsentApprove, cnf.ConsentDeny} {
+		if sel == "" {
+			continue
+		}
+		has, err := page.HasElement(sel)
+		if err != nil {
+			return nil, err
+		}
+		consent = consent || has
+	}
+	if !consent {
+		return page, nil
+	}
+	debugger.Debugln("The consent page is loaded")
+
+	button := cnf.ConsentApprove
+	if cnf.DenyConsent {
+		button = cnf.ConsentDeny
+	}
+	form, err := page.Form(button)
+	if err != nil {
+		return nil, err
+	}
+	if form == nil {
+		return nil, errors.New(errors.KindConsentButtonInvalid, "the consent page does not contain the button %q", button)
+	}
+
+	if !cnf.DenyConsent && cnf.ConsentScope != "" {
+		scopes := strings.Fields(cnf.GrantScopes)
+		offered, err := form.SetChecked(cnf.ConsentScope, func(v string) bool {
+			return len(scopes) == 0 || contains(scopes, v)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, scope := range scopes {
+			if !contains(offered, scope) {
+				return nil, errors.New(errors.KindConsentScopeInvalid, "the consent page does not offer the scope %q", scope)
+			}
+		}
+		if len(scopes) == 0 {
+			scopes = offered
+		}
+		debugger.Debugf("Grant scopes %q\n", scopes)
+	}
+
+	if cnf.DenyConsent {
+		debugger.Debugln("Deny the consent")
+	} else {
+		debugger.Debugln("Approve the consent")
+	}
+	return browser.submitForm(ctx, form)
+}
+
+// pageLoginError returns an error with the kind errors.KindLoginError when a page contains
+// an authentication error's message. The function returns nil when the page does not contain the message.
+func pageLoginError(page *htmlform.Page, sel string) error {
+	if page == nil {
+		return nil
+	}
+	errMsg, err := page.Text(sel)
+	if err != nil {
+		return errors.Wrap(err, "find submiting error message")
+	}
+	if errMsg = strings.TrimSpace(errMsg); errMsg != "" {
+		return errors.New(errors.KindLoginError, errMsg)
+	}
+	return nil
+}
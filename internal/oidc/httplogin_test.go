@@ -0,0 +1,109 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPBrowserNavigate(t *testing.T) {
+	// unavailable is an URL of a closed server.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	unavailable := closed.URL + "/unavailable"
+	closed.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/to-callback":
+			http.Redirect(w, r, "http://localhost:9000/auth-callback#access_token=foo", http.StatusFound)
+		case "/to-unavailable":
+			http.Redirect(w, r, unavailable, http.StatusFound)
+		case "/to-unavailable-client":
+			http.Redirect(w, r, unavailable+"#access_token=foo&state=bar", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body></body></html>"))
+		}
+	}))
+	defer srv.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		path     string
+		wantPage bool
+		wantLast string
+		wantErr  bool
+	}{
+		{
+			name:     "page",
+			path:     "/page",
+			wantPage: true,
+			wantLast: srv.URL + "/page",
+		},
+		{
+			name:     "redirect to the client's redirect uri",
+			path:     "/to-callback",
+			wantLast: "http://localhost:9000/auth-callback#access_token=foo",
+		},
+		{
+			name:    "redirect to an unavailable resource",
+			path:    "/to-unavailable",
+			wantErr: true,
+		},
+		{
+			name:     "redirect to an unavailable resource with an authentication response",
+			path:     "/to-unavailable-client",
+			wantLast: unavailable + "#access_token=foo&state=bar",
+		},
+		{
+			name:    "canceled context",
+			ctx:     canceled,
+			path:    "/to-callback",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			b, err := newHTTPBrowser("http://localhost:9000/auth-callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page, err := b.navigate(ctx, srv.URL+tc.path)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("\ngot no errors\nwant an error")
+				}
+				if tc.ctx != nil && err != context.Canceled {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, context.Canceled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if got := page != nil; got != tc.wantPage {
+				t.Fatalf("got page %v, want page %v", got, tc.wantPage)
+			}
+			if got := b.last(); got != tc.wantLast {
+				t.Fatalf("got the last navigation request %q, want %q", got, tc.wantLast)
+			}
+		})
+	}
+}
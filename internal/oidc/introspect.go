@@ -0,0 +1,149 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// Token type hints of the token introspection and revocation.
+const (
+	// TokenTypeAccess is the hint of an access token.
+	TokenTypeAccess = "access_token"
+	// TokenTypeRefresh is the hint of a refresh token.
+	TokenTypeRefresh = "refresh_token"
+)
+
+// IntrospectConfig is a configuration of the token introspection.
+type IntrospectConfig struct {
+	Endpoint      string           // an OpenID Connect endpoint
+	Metadata      ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	ClientID      string           // a client's ID
+	ClientAuth    ClientAuth       // a client's authentication at the introspection endpoint
+	Token         string           // an access token or a refresh token
+	TokenTypeHint string           // a type of the token: TokenTypeAccess or TokenTypeRefresh; optional
+}
+
+// Introspect requests the state of a token from the introspection endpoint. The client is authenticated
+// the same way as at the token endpoint.
+//
+// The function returns the introspection response. An inactive token is not an error,
+// the response contains the claim "active" with the value false in this case.
+//
+// See https://tools.ietf.org/html/rfc7662.
+func Introspect(ctx context.Context, cnf *IntrospectConfig) (map[string]interface{}, error) {
+	if err := validateTokenRequest(cnf.Endpoint, cnf.ClientID, &cnf.ClientAuth, cnf.Token); err != nil {
+		return nil, err
+	}
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, endpointIntrospection)
+	if err != nil {
+		return nil, err
+	}
+
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Introspect the token at the introspection endpoint %q\n", meta.IntrospectionEndpoint)
+	form := url.Values{}
+	form.Set("token", cnf.Token)
+	if cnf.TokenTypeHint != "" {
+		form.Set("token_type_hint", cnf.TokenTypeHint)
+	}
+	b, err := sendClientRequest(ctx, meta, meta.IntrospectionEndpoint, cnf.ClientID, &cnf.ClientAuth, form)
+	if err != nil {
+		return nil, err
+	}
+	var resp map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&resp); err != nil {
+		return nil, errors.Wrap(err, "parse introspection response")
+	}
+	if _, ok := resp["active"].(bool); !ok {
+		return nil, errors.New("introspection response does not contain the claim active")
+	}
+	return resp, nil
+}
+
+// validateTokenRequest checks parameters of the introspection and revocation requests.
+func validateTokenRequest(endpoint, clientID string, auth *ClientAuth, token string) error {
+	if endpoint == "" {
+		return errors.New(errors.KindEndpointMissed, "OpenID Connect endpoint is missed")
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return errors.New(errors.KindEndpointInvalid, "OpenID Connect endpoint has an invalid value")
+	}
+	if clientID == "" {
+		return errors.New(errors.KindClientIDMissed, "client ID is missed")
+	}
+	if token == "" {
+		return errors.New(errors.KindTokenMissed, "token is missed")
+	}
+	return auth.validate()
+}
+
+// sendClientRequest sends a form to an endpoint of the authorization server on behalf of a client,
+// and returns the response's body.
+//
+// The client is authenticated the same way as at the token endpoint. The audience of a client assertion
+// is the token endpoint when the provider defines it, because of providers accept it for all endpoints.
+// When the endpoint responds with an error the function returns an error with the kind errors.KindOIDCError.
+func sendClientRequest(ctx context.Context, meta *ProviderMetadata, endpoint, clientID string, auth *ClientAuth, form url.Values) ([]byte, error) {
+	audience := meta.TokenEndpoint
+	if audience == "" {
+		audience = endpoint
+	}
+	header := http.Header{}
+	if err := auth.apply(clientID, audience, form, header); err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "create request to %q", endpoint)
+	}
+	r.Header = header
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+
+	reqCtx, cancelReqCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelReqCtx()
+	resp, err := http.DefaultClient.Do(r.WithContext(reqCtx))
+	if err != nil {
+		return nil, errors.Wrap(err, "send request to %q", endpoint)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read response")
+	}
+	if resp.StatusCode == http.StatusOK {
+		return b, nil
+	}
+
+	// See https://tools.ietf.org/html/rfc6749#section-5.2.
+	var errResp struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(b, &errResp) == nil && errResp.Error != "" {
+		msg := errResp.Error
+		if errResp.ErrorDescription != "" {
+			msg = fmt.Sprintf("%s: %s", msg, errResp.ErrorDescription)
+		}
+		return nil, errors.New(errors.KindOIDCError, msg)
+	}
+	return nil, errors.New("%q responded with status code %d", endpoint, resp.StatusCode)
+}
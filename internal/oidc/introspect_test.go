@@ -0,0 +1,206 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+// newTokenServer returns a server that serves the introspection and revocation endpoints.
+// The server responds to every request of the endpoints with the status and response, and sends the request's form to forms.
+func newTokenServer(t *testing.T, status int, resp string, forms chan<- url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			issuer := "http://" + r.Host
+			fmt.Fprintf(w, `{"issuer": %q, "token_endpoint": %q, "introspection_endpoint": %q, "revocation_endpoint": %q}`,
+				issuer, issuer+"/token", issuer+"/introspect", issuer+"/revoke")
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %s", err)
+		}
+		if user, pass, ok := r.BasicAuth(); ok {
+			r.PostForm.Set("basic", user+":"+pass)
+		}
+		r.PostForm.Set("path", r.URL.Path)
+		forms <- r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, resp)
+	}))
+}
+
+func TestIntrospect(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cnf      IntrospectConfig
+		status   int
+		resp     string
+		wantForm url.Values
+		want     map[string]interface{}
+		wantErr  error
+	}{
+		{
+			name:    "client ID is missed",
+			cnf:     IntrospectConfig{Token: "foo"},
+			wantErr: errors.New(errors.KindClientIDMissed),
+		},
+		{
+			name:    "token is missed",
+			cnf:     IntrospectConfig{ClientID: "test-client"},
+			wantErr: errors.New(errors.KindTokenMissed),
+		},
+		{
+			name:    "client authentication is invalid",
+			cnf:     IntrospectConfig{ClientID: "test-client", Token: "foo", ClientAuth: ClientAuth{Method: "foo"}},
+			wantErr: errors.New(errors.KindClientAuthInvalid),
+		},
+		{
+			name:     "client is rejected",
+			cnf:      IntrospectConfig{ClientID: "test-client", Token: "foo"},
+			status:   http.StatusUnauthorized,
+			resp:     `{"error": "invalid_client", "error_description": "Client authentication failed"}`,
+			wantForm: url.Values{"path": {"/introspect"}, "client_id": {"test-client"}, "token": {"foo"}},
+			wantErr:  errors.New(errors.KindOIDCError),
+		},
+		{
+			name:     "server error",
+			cnf:      IntrospectConfig{ClientID: "test-client", Token: "foo"},
+			status:   http.StatusInternalServerError,
+			wantForm: url.Values{"path": {"/introspect"}, "client_id": {"test-client"}, "token": {"foo"}},
+			wantErr:  errors.New(errors.KindOther),
+		},
+		{
+			name:     "claim active is missed",
+			cnf:      IntrospectConfig{ClientID: "test-client", Token: "foo"},
+			status:   http.StatusOK,
+			resp:     `{"sub": "foo"}`,
+			wantForm: url.Values{"path": {"/introspect"}, "client_id": {"test-client"}, "token": {"foo"}},
+			wantErr:  errors.New(errors.KindOther),
+		},
+		{
+			name:     "inactive token",
+			cnf:      IntrospectConfig{ClientID: "test-client", Token: "foo", TokenTypeHint: TokenTypeRefresh},
+			status:   http.StatusOK,
+			resp:     `{"active": false}`,
+			wantForm: url.Values{"path": {"/introspect"}, "client_id": {"test-client"}, "token": {"foo"}, "token_type_hint": {"refresh_token"}},
+			want:     map[string]interface{}{"active": false},
+		},
+		{
+			name: "active token",
+			cnf: IntrospectConfig{
+				ClientID:      "test-client",
+				ClientAuth:    ClientAuth{Secret: "secret"},
+				Token:         "foo",
+				TokenTypeHint: TokenTypeAccess,
+			},
+			status:   http.StatusOK,
+			resp:     `{"active": true, "sub": "foo", "exp": 1556712000}`,
+			wantForm: url.Values{"path": {"/introspect"}, "basic": {"test-client:secret"}, "token": {"foo"}, "token_type_hint": {"access_token"}},
+			want:     map[string]interface{}{"active": true, "sub": "foo", "exp": json.Number("1556712000")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			forms := make(chan url.Values, 1)
+			srv := newTokenServer(t, tc.status, tc.resp, forms)
+			defer srv.Close()
+
+			tc.cnf.Endpoint = srv.URL
+			got, err := Introspect(context.Background(), &tc.cnf)
+
+			if tc.wantForm != nil {
+				if form := <-forms; !reflect.DeepEqual(form, tc.wantForm) {
+					t.Errorf("got form %v, want %v", form, tc.wantForm)
+				}
+			}
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cnf      RevokeConfig
+		status   int
+		resp     string
+		wantForm url.Values
+		wantErr  error
+	}{
+		{
+			name:    "token is missed",
+			cnf:     RevokeConfig{ClientID: "test-client"},
+			wantErr: errors.New(errors.KindTokenMissed),
+		},
+		{
+			name:     "unsupported token type",
+			cnf:      RevokeConfig{ClientID: "test-client", Token: "foo", TokenTypeHint: TokenTypeAccess},
+			status:   http.StatusBadRequest,
+			resp:     `{"error": "unsupported_token_type"}`,
+			wantForm: url.Values{"path": {"/revoke"}, "client_id": {"test-client"}, "token": {"foo"}, "token_type_hint": {"access_token"}},
+			wantErr:  errors.New(errors.KindOIDCError),
+		},
+		{
+			name:     "token is revoked",
+			cnf:      RevokeConfig{ClientID: "test-client", ClientAuth: ClientAuth{Secret: "secret"}, Token: "foo"},
+			status:   http.StatusOK,
+			wantForm: url.Values{"path": {"/revoke"}, "basic": {"test-client:secret"}, "token": {"foo"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			forms := make(chan url.Values, 1)
+			srv := newTokenServer(t, tc.status, tc.resp, forms)
+			defer srv.Close()
+
+			tc.cnf.Endpoint = srv.URL
+			err := Revoke(context.Background(), &tc.cnf)
+
+			if tc.wantForm != nil {
+				if form := <-forms; !reflect.DeepEqual(form, tc.wantForm) {
+					t.Errorf("got form %v, want %v", form, tc.wantForm)
+				}
+			}
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+		})
+	}
+}
@@ -8,45 +8,76 @@ package oidc
 
 import (
 	"context"
-	"fmt"
+	"crypto/sha256"
+	"encoding/base64"
 	"net/url"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/chromedp/chromedp"
 	"github.com/i-core/tokget/internal/chrome"
 	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
 	"github.com/i-core/tokget/internal/log"
-	"golang.org/x/crypto/ssh/terminal"
+	"github.com/i-core/tokget/internal/prompt"
+)
+
+// OAuth2 flows that are supported by the login process.
+const (
+	// FlowImplicit is the implicit flow. Tokens are returned in the fragment of the client's redirect uri.
+	FlowImplicit = "implicit"
+	// FlowCode is the authorization code flow with PKCE. An authorization code is returned in the query
+	// of the client's redirect uri and exchanged to tokens at the token endpoint.
+	FlowCode = "code"
 )
 
 // LoginConfig is a configuration of the login process.
 type LoginConfig struct {
-	Endpoint      string // an OpenID Connect endpoint
-	ClientID      string // a client's ID
-	RedirectURI   string // a client's redirect uri
-	Scopes        string // OpenID Connect scopes
-	Username      string // a user's name
-	Password      string // a user's password
-	PasswordStdin bool   // a user's password from stdin
-	UsernameField string // a CSS selector of the username field on the login form
-	PasswordField string // a CSS selector of the password field on the login form
-	SubmitButton  string // a CSS selector of the submit button on the login form
-	ErrorMessage  string // a CSS selector of an error message on the login form
+	Endpoint       string           // an OpenID Connect endpoint
+	Engine         string           // an engine that runs the login process: EngineChrome (by default) or EngineHTTP
+	Metadata       ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	Flow           string           // an OAuth2 flow: FlowImplicit (by default) or FlowCode
+	ClientID       string           // a client's ID
+	ClientAuth     ClientAuth       // a client's authentication at the token endpoint (used in the authorization code flow)
+	RedirectURI    string           // a client's redirect uri
+	Scopes         string           // OpenID Connect scopes
+	Audience       string           // an audience of the access token (the parameter "audience" that some providers support)
+	Username       string           // a user's name
+	Password       string           // a user's password
+	PasswordStdin  bool             // a user's password from stdin
+	UsernameField  string           // a CSS selector of the username field on the login form
+	PasswordField  string           // a CSS selector of the password field on the login form
+	SubmitButton   string           // a CSS selector of the submit button on the login form
+	UsernameSubmit string           // a CSS selector of the submit button on the username page of the multi-step login
+	Script         *Script          // a login script; when it is nil the username and password are filled by the default script
+	TOTP           TOTP             // a second factor by Time-Based One-Time Passwords
+	WebAuthn       WebAuthn         // a passwordless login by a passkey instead of the password
+	ErrorMessage   string           // a CSS selector of an error message on the login form
+	ConsentScope   string           // a CSS selector of the scope checkboxes on the consent page; a checkbox's value is a scope
+	ConsentApprove string           // a CSS selector of the approve button on the consent page
+	ConsentDeny    string           // a CSS selector of the deny button on the consent page
+	GrantScopes    string           // scopes to grant on the consent page; all offered scopes when it is empty
+	DenyConsent    bool             // deny the consent instead of approving it
+	Verify         bool             // verify the ID token's signature and claims
+	Expect         []*ClaimCheck    // expected claims of the tokens; the login fails when a token does not match them
 }
 
 // LoginData is a successful result of the login process.
 type LoginData struct {
-	AccessToken string `json:"access_token"`
-	IDToken     string `json:"id_token"`
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"` // scopes of the access token when the provider returns them
+	// UserInfo contains claims from the UserInfo endpoint. The login process does not request them, see UserInfo.
+	UserInfo map[string]interface{} `json:"userinfo,omitempty"`
 }
 
 var pwdFromStdin = defaultPwdFromStdin
 
 // defaultPwdFromStdin reads a password, without echo, from the stdin.
 func defaultPwdFromStdin() (string, error) {
-	fmt.Println("Enter password: ")
-	b, err := terminal.ReadPassword(0)
+	b, err := prompt.Password("Enter password: ")
 	if err != nil {
 		return "", err
 	}
@@ -55,6 +86,8 @@ func defaultPwdFromStdin() (string, error) {
 
 // Login authenticates a user by opening the login page of an OpenID Connect Provider,
 // and emulating user's actions to fill the authentication parameters and clicking the login button.
+// When the configuration defines the engine EngineHTTP the login page is loaded and submitted
+// by plain HTTP requests instead of Chrome, and chromeURL is not used.
 // The function returns a struct that contains an access token an ID token of the authenticated user.
 func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData, error) {
 	debugger := log.DebuggerFromContext(ctx)
@@ -119,10 +152,55 @@ func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData,
 		}
 	}
 
-	endpoint, err := url.Parse(cnf.Endpoint)
+	_, err := url.Parse(cnf.Endpoint)
 	if err != nil {
 		return nil, errors.New(errors.KindEndpointInvalid, "OpenID Connect endpoint has an invalid value")
 	}
+	requiredEndpoints := []endpoint{endpointAuthorization}
+	switch cnf.Flow {
+	case "", FlowImplicit:
+	case FlowCode:
+		requiredEndpoints = append(requiredEndpoints, endpointToken)
+		if err = cnf.ClientAuth.validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New(errors.KindFlowInvalid, "OAuth2 flow %q is not supported", cnf.Flow)
+	}
+	switch cnf.Engine {
+	case "", EngineChrome:
+	case EngineHTTP:
+		if cnf.Script != nil {
+			return nil, errors.New(errors.KindEngineInvalid, "the engine %q does not support login scripts", cnf.Engine)
+		}
+		if cnf.WebAuthn.enabled() {
+			return nil, errors.New(errors.KindEngineInvalid, "the engine %q does not support WebAuthn", cnf.Engine)
+		}
+	default:
+		return nil, errors.New(errors.KindEngineInvalid, "login engine %q is not supported", cnf.Engine)
+	}
+	if cnf.Script != nil {
+		if err = cnf.Script.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cnf.TOTP.enabled() {
+		if err = cnf.TOTP.validate(); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		webAuthnStored *webAuthnCredentialFile
+		webAuthnCred   *chrome.WebAuthnCredential
+	)
+	if cnf.WebAuthn.enabled() {
+		if webAuthnStored, webAuthnCred, err = loadWebAuthnCredential(cnf.WebAuthn.CredentialFile); err != nil {
+			return nil, err
+		}
+	}
+	if cnf.Verify {
+		requiredEndpoints = append(requiredEndpoints, endpointJWKS)
+	}
 
 	password := cnf.Password
 	if cnf.PasswordStdin {
@@ -132,31 +210,87 @@ func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData,
 		}
 	}
 
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, requiredEndpoints...)
+	if err != nil {
+		return nil, err
+	}
+
+	authReq := &authRequest{
+		clientID:    cnf.ClientID,
+		clientAuth:  &cnf.ClientAuth,
+		redirectURI: cnf.RedirectURI,
+		scopes:      cnf.Scopes,
+		audience:    cnf.Audience,
+		verify:      cnf.Verify,
+		expect:      cnf.Expect,
+	}
+	// State and nonce are unique for each login to protect against CSRF and replay attacks.
+	if authReq.state, err = randomString(16); err != nil {
+		return nil, errors.Wrap(err, "generate state")
+	}
+	if authReq.nonce, err = randomString(16); err != nil {
+		return nil, errors.Wrap(err, "generate nonce")
+	}
+	if cnf.Flow == FlowCode {
+		if authReq.codeVerifier, err = randomString(32); err != nil {
+			return nil, errors.Wrap(err, "generate PKCE code verifier")
+		}
+	}
+
+	// The timer reports durations of the login process's steps when the login is timed, for example, by Bench.
+	timer := newStepTimer(ctx)
+	ctx = withStepTimer(ctx, timer)
+
+	// The engine http loads the login page and submits its forms without Chrome.
+	if cnf.Engine == EngineHTTP {
+		return loginHTTP(ctx, cnf, meta, authReq, password)
+	}
+
 	//
 	// Step 2. Initialize Chrome connection and open a new tab.
 	//
+	domains := []chrome.Domain{chrome.DomainNetwork, chrome.DomainRuntime}
+	if cnf.WebAuthn.enabled() {
+		domains = append(domains, chrome.DomainWebAuthn)
+	}
 	var cancelBrowser context.CancelFunc
-	if ctx, cancelBrowser, err = chrome.ConnectWithContext(ctx, chromeURL, chrome.DomainNetwork, chrome.DomainRuntime); err != nil {
+	if ctx, cancelBrowser, err = chrome.ConnectWithContext(ctx, chromeURL, domains...); err != nil {
 		return nil, errors.Wrap(err, "connect to chrome")
 	}
 	defer func() {
 		debugger.Debugln("Disconnect Chrome")
 		cancelBrowser()
 	}()
+	// A tab of a shared Chrome session does not inherit the values of the login's context.
+	ctx = withStepTimer(ctx, timer)
 
 	navHistory, err := chrome.NewNavHistory(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "initialize navigation history")
 	}
 
+	// The virtual authenticator answers the passkey ceremony that the login page starts.
+	var authenticatorID string
+	if cnf.WebAuthn.enabled() {
+		debugger.Debugln("Attach a virtual WebAuthn authenticator")
+		if authenticatorID, err = chrome.AddVirtualAuthenticator(ctx, webAuthnCred); err != nil {
+			return nil, err
+		}
+	}
+
 	//
 	// Step 3. Navigate to the OpenID Connect Provider's login page.
 	//
-	loginStartURL := buildLoginURL(endpoint, cnf.ClientID, cnf.RedirectURI, cnf.Scopes)
+	loginStartURL, err := buildLoginURL(meta.AuthorizationEndpoint, authReq)
+	if err != nil {
+		return nil, err
+	}
 	debugger.Debugf("Navigate to the login page %q\n", loginStartURL)
+	timer.start()
 	if err = chrome.Navigate(ctx, loginStartURL); err != nil {
 		return nil, errors.Wrap(err, "navigate to the login page")
 	}
+	timer.finish(StepNavigate)
 	if err = extractOIDCError(navHistory.Last()); err != nil {
 		return nil, err
 	}
@@ -167,70 +301,75 @@ func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData,
 	debugger.Debugf("The login page is loaded:\n\n%s\n\n", loginPageContent)
 
 	//
-	// Step 4. Validate the login form.
+	// Step 4. Run the login script that fills and submits the login form.
 	//
-	// We expect that the login form contains the username field, password field and submit button.
-	debugger.Debugln("Fill the login form")
-	formHasElement := func(name, sel string, kind errors.Kind) error {
-		has, hasErr := chrome.HasElement(ctx, sel)
-		if hasErr != nil {
-			return errors.Wrap(hasErr, "find %s", name)
+	// The default script fills the username field and password field, and clicks the submit button.
+	// A custom script can handle cookie banners, tenant pickers and other pages between the login page
+	// and the client's redirect URI.
+	script := cnf.Script
+	if script == nil {
+		script = defaultScript(cnf)
+	}
+	debugger.Debugln("Run the login script")
+	vars := strings.NewReplacer("${username}", cnf.Username, "${password}", password)
+	if err = runScript(ctx, script.Steps, vars); err != nil {
+		// A step can fail because of the OpenID Connect Provider shows an error instead of the expected page,
+		// for example, about an unknown user on the username page of the multi-step login.
+		if oidcErr := extractOIDCError(navHistory.Last()); oidcErr != nil {
+			return nil, oidcErr
 		}
-		if !has {
-			return errors.New(kind, "the login form does not contains %s", name)
+		if loginErr := extractLoginError(ctx, cnf.ErrorMessage); loginErr != nil {
+			return nil, loginErr
 		}
-		return nil
-	}
-	if err = formHasElement("the username field", cnf.UsernameField, errors.KindUsernameFieldInvalid); err != nil {
-		return nil, err
-	}
-	if err = formHasElement("the password field", cnf.PasswordField, errors.KindPasswordFieldInvalid); err != nil {
-		return nil, err
-	}
-	if err = formHasElement("the submit button", cnf.SubmitButton, errors.KindSubmitButtonInvalid); err != nil {
 		return nil, err
 	}
+	timer.finish(StepSubmit)
 
 	//
-	// Step 5. Fill the login form.
+	// Step 5. Pass the second factor if the OpenID Connect Provider shows the one-time password page.
 	//
-	if err = chromedp.Run(ctx, chromedp.SendKeys(cnf.UsernameField, cnf.Username)); err != nil {
-		return nil, errors.Wrap(err, "fill the username field")
-	}
-	if password != "" {
-		if err = chromedp.Run(ctx, chromedp.SendKeys(cnf.PasswordField, password)); err != nil {
-			return nil, errors.Wrap(err, "fill the password field")
+	if cnf.TOTP.enabled() {
+		if err = passTOTP(ctx, &cnf.TOTP, cnf.ErrorMessage); err != nil {
+			return nil, err
 		}
 	}
 
 	//
-	// Step 6. Submit the login form.
+	// Step 6. Approve or deny the consent if the OpenID Connect Provider shows the consent page.
 	//
-	debugger.Debugln("Submit the login form")
-	// We submit the login form by clicking on the submit button instead of calling chromedp.Submit()
-	// because of the tool emulates a user's actions.
-	wait := chrome.PageLoadWaiterFunc(ctx, false, 5*time.Second)
-	if err = chromedp.Run(ctx, chromedp.Click(cnf.SubmitButton)); err != nil {
-		return nil, errors.Wrap(err, "submit the login form")
+	debugger.Debugln("Submiting is finished")
+	consent, err := isConsentPage(ctx, cnf)
+	if err != nil {
+		return nil, err
 	}
-	if err = wait(); err != nil {
-		return nil, errors.Wrap(err, "wait for submiting the login form")
+	if consent {
+		debugger.Debugln("The consent page is loaded")
+		if err = submitConsent(ctx, cnf); err != nil {
+			return nil, err
+		}
 	}
 
 	//
 	// Step 7. Handle the submiting result.
 	//
 	// There are the next cases:
-	// 1. The OpenID Connect Provider redirects a user to the client's redirect URI with tokens in the URL's fragment.
-	// 2. The OpenID Connect Provider redirects a user to an OpenID Connect error's page.
+	// 1. The OpenID Connect Provider redirects a user to the client's redirect URI with tokens in the URL's fragment
+	//    (the implicit flow) or with an authorization code in the URL's query (the authorization code flow).
+	// 2. The OpenID Connect Provider redirects a user to an OpenID Connect error's page
+	//    (or to the client's redirect URI with an error when the consent is denied).
 	// 3. The OpenID Connect Provider shows a user the login page that contains authentication error's message.
-	debugger.Debugln("Submiting is finished")
 	postLoginURL := navHistory.Last()
-	loginData, err := extractOIDCTokens(postLoginURL)
+	loginData, err := extractLoginData(ctx, postLoginURL, meta, authReq)
 	if err != nil {
-		return nil, errors.Wrap(err, "extract OpenID Connect tokens")
+		return nil, err
 	}
 	if loginData != nil {
+		timer.finish(StepCallback)
+		if cnf.WebAuthn.enabled() {
+			if err = saveSignCount(ctx, cnf.WebAuthn.CredentialFile, webAuthnStored, authenticatorID); err != nil {
+				return nil, err
+			}
+		}
 		return loginData, nil
 	}
 
@@ -238,12 +377,8 @@ func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData,
 	if err = extractOIDCError(postLoginURL); err != nil {
 		return nil, err
 	}
-	errMsg, err := chrome.Text(ctx, cnf.ErrorMessage)
-	if err != nil {
-		return nil, errors.Wrap(err, "find submiting error message")
-	}
-	if errMsg != "" {
-		return nil, errors.New(errors.KindLoginError, strings.TrimSpace(errMsg))
+	if err = extractLoginError(ctx, cnf.ErrorMessage); err != nil {
+		return nil, err
 	}
 	// There is an unexpected error page so just display the page's content to a user.
 	var errPageContent string
@@ -253,24 +388,179 @@ func Login(ctx context.Context, chromeURL string, cnf *LoginConfig) (*LoginData,
 	return nil, errors.New(errors.KindLoginError, "unexpected error page %q\n%s", postLoginURL, errPageContent)
 }
 
-func buildLoginURL(endpoint *url.URL, clientID, redirectURI, scopes string) string {
-	ref, err := url.Parse("/oauth2/auth")
+// extractLoginError returns an error with the kind errors.KindLoginError when the current page
+// contains an authentication error's message. The function returns nil when the page does not contain the message.
+func extractLoginError(ctx context.Context, sel string) error {
+	errMsg, err := chrome.Text(ctx, sel)
+	if err != nil {
+		return errors.Wrap(err, "find submiting error message")
+	}
+	if errMsg = strings.TrimSpace(errMsg); errMsg != "" {
+		return errors.New(errors.KindLoginError, errMsg)
+	}
+	return nil
+}
+
+// authRequest contains parameters of an authentication request.
+type authRequest struct {
+	clientID    string
+	clientAuth  *ClientAuth
+	redirectURI string
+	scopes      string
+	audience    string
+	state       string
+	nonce       string
+	// codeVerifier is a PKCE code verifier. It is defined only in the authorization code flow.
+	codeVerifier string
+	// verify enables the verification of the ID token's signature and claims.
+	verify bool
+	// expect contains expected claims of the tokens.
+	expect []*ClaimCheck
+}
+
+func buildLoginURL(authEndpoint string, req *authRequest) (string, error) {
+	loginStartURL, err := url.Parse(authEndpoint)
 	if err != nil {
-		panic(errors.Wrap(err, "make login url"))
+		return "", errors.New(errors.KindEndpointInvalid, "authorization endpoint has an invalid value")
 	}
-	loginStartURL := endpoint.ResolveReference(ref)
 	query := loginStartURL.Query()
-	query.Set("client_id", clientID)
+	query.Set("client_id", req.clientID)
 	query.Set("response_type", "id_token token")
-	query.Set("scope", scopes)
-	query.Set("redirect_uri", redirectURI)
-	query.Set("state", "12345678")
-	query.Set("nonce", "87654321")
+	query.Set("scope", req.scopes)
+	query.Set("redirect_uri", req.redirectURI)
+	query.Set("state", req.state)
+	query.Set("nonce", req.nonce)
+	if req.audience != "" {
+		query.Set("audience", req.audience)
+	}
+	if req.codeVerifier != "" {
+		query.Set("response_type", "code")
+		query.Set("code_challenge", pkceChallenge(req.codeVerifier))
+		query.Set("code_challenge_method", "S256")
+	}
 	loginStartURL.RawQuery = query.Encode()
-	return loginStartURL.String()
+	return loginStartURL.String(), nil
+}
+
+// pkceChallenge returns a PKCE code challenge for a code verifier by the method "S256".
+//
+// See https://tools.ietf.org/html/rfc7636#section-4.2.
+func pkceChallenge(verifier string) string {
+	h := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// extractLoginData returns tokens from the authentication callback's URL.
+//
+// In the implicit flow tokens are taken from the URL's fragment.
+// In the authorization code flow an authorization code is taken from the URL's query,
+// and exchanged to tokens at the token endpoint.
+//
+// The function checks that the callback's state and the ID token's nonce equal to the authentication request's ones.
+// When the request enables the verification the function also verifies the ID token's signature and claims.
+// Finally, the function checks the tokens against the request's expected claims.
+// The function returns nil when the URL does not contain tokens or an authorization code.
+func extractLoginData(ctx context.Context, u string, meta *ProviderMetadata, req *authRequest) (*LoginData, error) {
+	var loginData *LoginData
+	if req.codeVerifier == "" {
+		var err error
+		if loginData, err = extractOIDCTokens(u, req.state); err != nil {
+			return nil, err
+		}
+	} else {
+		code, err := extractAuthCode(u, req.redirectURI, req.state)
+		if err != nil {
+			return nil, err
+		}
+		if code == "" {
+			return nil, nil
+		}
+		form := url.Values{}
+		form.Set("grant_type", "authorization_code")
+		form.Set("code", code)
+		form.Set("redirect_uri", req.redirectURI)
+		form.Set("code_verifier", req.codeVerifier)
+		if loginData, err = requestToken(ctx, meta.TokenEndpoint, req.clientID, req.clientAuth, form); err != nil {
+			return nil, errors.Wrap(err, "exchange authorization code")
+		}
+	}
+	if loginData == nil {
+		return nil, nil
+	}
+	if req.verify {
+		checks := &idTokenChecks{
+			issuer:      meta.Issuer,
+			clientID:    req.clientID,
+			nonce:       req.nonce,
+			accessToken: loginData.AccessToken,
+			implicit:    req.codeVerifier == "",
+		}
+		if err := verifyIDToken(ctx, meta.JWKSURI, loginData.IDToken, checks); err != nil {
+			return nil, err
+		}
+	} else if err := checkNonce(loginData.IDToken, req.nonce); err != nil {
+		return nil, err
+	}
+	if err := checkClaims(loginData, req.expect); err != nil {
+		return nil, err
+	}
+	return loginData, nil
+}
+
+// checkState checks that the authentication callback's state equals to the authentication request's state.
+func checkState(got, want string) error {
+	if got != want {
+		return errors.New(errors.KindStateMismatch, "the authentication callback's state %q does not match the authentication request's state", got)
+	}
+	return nil
 }
 
-func extractOIDCTokens(u string) (*LoginData, error) {
+// checkNonce checks that the claim "nonce" of an ID token equals to the authentication request's nonce.
+//
+// The authorization code flow can issue no ID token (when the scope "openid" is not requested) so the function
+// does nothing for an empty ID token.
+func checkNonce(idToken, want string) error {
+	if idToken == "" {
+		return nil
+	}
+	tok, err := jwt.Parse(idToken)
+	if err != nil {
+		return errors.Wrap(err, "parse ID token")
+	}
+	if got := tok.String("nonce"); got != want {
+		return errors.New(errors.KindNonceMismatch, "the ID token's nonce %q does not match the authentication request's nonce", got)
+	}
+	return nil
+}
+
+// extractAuthCode returns an authorization code from the query of the client's redirect uri.
+//
+// The function returns an empty string when the URL is not the client's redirect uri,
+// or it does not contain an authorization code.
+func extractAuthCode(u, redirectURI, state string) (string, error) {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return "", errors.Wrap(err, "parse post login URL")
+	}
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", errors.Wrap(err, "parse client's redirect uri")
+	}
+	if parsedURL.Scheme != redirectURL.Scheme || parsedURL.Host != redirectURL.Host || parsedURL.Path != redirectURL.Path {
+		return "", nil
+	}
+	query := parsedURL.Query()
+	code := query.Get("code")
+	if code == "" {
+		return "", nil
+	}
+	if err = checkState(query.Get("state"), state); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func extractOIDCTokens(u, state string) (*LoginData, error) {
 	parsedURL, err := url.Parse(u)
 	if err != nil {
 		return nil, errors.Wrap(err, "parse post login URL")
@@ -282,6 +572,12 @@ func extractOIDCTokens(u string) (*LoginData, error) {
 	if userData, err = url.ParseQuery(parsedURL.Fragment); err != nil {
 		return nil, errors.Wrap(err, "parse the authentication callback's fragment")
 	}
+	if userData.Get("error") != "" {
+		return nil, extractOIDCError(u)
+	}
+	if err = checkState(userData.Get("state"), state); err != nil {
+		return nil, err
+	}
 	accessToken := userData.Get("access_token")
 	if accessToken == "" {
 		return nil, errors.New("the authentication endpoint does not send an access token in the url's fragment")
@@ -290,5 +586,11 @@ func extractOIDCTokens(u string) (*LoginData, error) {
 	if idToken == "" {
 		return nil, errors.New("the authentication endpoint does not send an id token in the url's fragment")
 	}
-	return &LoginData{AccessToken: accessToken, IDToken: idToken}, nil
+	loginData := &LoginData{AccessToken: accessToken, IDToken: idToken, Scope: userData.Get("scope")}
+	if v := userData.Get("expires_in"); v != "" {
+		if loginData.ExpiresIn, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, errors.Wrap(err, "parse expires_in of the authentication callback")
+		}
+	}
+	return loginData, nil
 }
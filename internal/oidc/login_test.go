@@ -8,6 +8,7 @@ package oidc
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -15,10 +16,15 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/i-core/tokget/internal/chrome"
 	"github.com/i-core/tokget/internal/errors"
 	"github.com/i-core/tokget/internal/log"
+	"github.com/i-core/tokget/internal/totp"
 )
 
 func TestLogin(t *testing.T) {
@@ -36,6 +42,7 @@ func TestLogin(t *testing.T) {
 		status    int
 		redirect  string
 		html      string
+		json      string
 		wantQuery map[string]interface{}
 		wantBody  interface{}
 	}
@@ -56,10 +63,21 @@ func TestLogin(t *testing.T) {
 		"response_type": "id_token token",
 		"scope":         "openid profile email",
 		"redirect_uri":  "http://localhost:9000/auth-callback",
-		"state":         "12345678",
-		"nonce":         "87654321",
+		"state":         anyValue,
+		"nonce":         anyValue,
 	}
 
+	// The TOTP code depends on the time so the time is fixed.
+	now := time.Unix(1500000010, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	const totpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	totpKey, err := totp.DecodeSecret(totpSecret)
+	if err != nil {
+		t.Fatalf("failed to decode TOTP secret: %s", err)
+	}
+	totpCode := totp.Code(totpKey, totp.Counter(now))
+
 	errStr := func(err error) string {
 		if v, ok := err.(*errors.Error); ok && v.Kind != errors.KindOther {
 			return v.Kind.String()
@@ -73,7 +91,11 @@ func TestLogin(t *testing.T) {
 		cnf          *LoginConfig
 		wantAccToken string
 		wantIDToken  string
+		wantRefToken string
+		wantExpires  int64
 		wantErr      error
+		// chromeOnly is true when the case uses a feature that is not supported by the engine http.
+		chromeOnly bool
 	}{
 		{
 			name:    "endpoint is missed",
@@ -213,6 +235,62 @@ func TestLogin(t *testing.T) {
 			},
 			wantErr: errors.New(errors.KindErrorMessageMissed),
 		},
+		{
+			name: "flow is invalid",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf: &LoginConfig{
+				Flow:          "hybrid",
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+			},
+			wantErr: errors.New(errors.KindFlowInvalid),
+		},
+		{
+			name: "engine is invalid",
+			cnf: &LoginConfig{
+				Endpoint:      "http://op",
+				Engine:        "firefox",
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+			},
+			wantErr: errors.New(errors.KindEngineInvalid),
+		},
+		{
+			name: "engine http does not support login scripts",
+			cnf: &LoginConfig{
+				Endpoint:      "http://op",
+				Engine:        EngineHTTP,
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				Script:        &Script{Steps: []*Step{{Action: ActionClick, Selector: "#submit"}}},
+			},
+			wantErr: errors.New(errors.KindEngineInvalid),
+		},
 		{
 			name: "login page error: invalid client id",
 			endpoints: []endpoint{
@@ -363,13 +441,442 @@ func TestLogin(t *testing.T) {
 				{
 					path:     "/handle-auth",
 					status:   http.StatusPermanentRedirect,
-					redirect: "http://localhost:3000#access_token=access_token_value&id_token=id_token_value",
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
 					wantBody: map[string]interface{}{"user": "foo", "pass": "bar"},
 				},
 			},
 			cnf:          testCnf,
 			wantAccToken: "access_token_value",
-			wantIDToken:  "id_token_value",
+			wantIDToken:  "{id_token}",
+		},
+		{
+			name: "state mismatch",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state=12345678",
+				},
+			},
+			cnf:     testCnf,
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+		{
+			name: "nonce mismatch",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={foreign_id_token}&state={state}",
+				},
+			},
+			cnf:     testCnf,
+			wantErr: errors.New(errors.KindNonceMismatch),
+		},
+		{
+			name: "authorization code flow",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:9000/auth-callback?code=code_value&state={state}",
+					wantBody: map[string]interface{}{"user": "foo", "pass": "bar"},
+				},
+				{
+					path:   "/oauth2/token",
+					status: http.StatusOK,
+					json: `{
+						"access_token": "access_token_value",
+						"id_token": "{id_token}",
+						"refresh_token": "refresh_token_value",
+						"token_type": "bearer",
+						"expires_in": 3600
+					}`,
+				},
+			},
+			cnf: &LoginConfig{
+				Flow:          FlowCode,
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email offline_access",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+			},
+			wantAccToken: "access_token_value",
+			wantIDToken:  "{id_token}",
+			wantRefToken: "refresh_token_value",
+			wantExpires:  3600,
+		},
+		{
+			name: "multi-step login",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlUsernameForm("/handle-username"),
+				},
+				{
+					path:     "/handle-username",
+					status:   http.StatusOK,
+					html:     htmlPasswordForm("/handle-auth"),
+					wantBody: map[string]interface{}{"user": "foo"},
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
+					wantBody: map[string]interface{}{"pass": "bar"},
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:       "test-client",
+				RedirectURI:    "http://localhost:9000/auth-callback",
+				Scopes:         "openid profile email",
+				Username:       "foo",
+				Password:       "bar",
+				UsernameField:  "#user",
+				PasswordField:  "#pass",
+				SubmitButton:   "#submit",
+				UsernameSubmit: "#next",
+				ErrorMessage:   "#error",
+			},
+			wantAccToken: "access_token_value",
+			wantIDToken:  "{id_token}",
+		},
+		{
+			name: "multi-step login: unknown user",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlUsernameForm("/handle-username"),
+				},
+				{
+					path:   "/handle-username",
+					status: http.StatusOK,
+					html: `
+						<html>
+							<body>
+								<form method="post" action="/handle-username">
+									<input id="user" name="user"/>
+									<button id="next">next</button>
+									<div id="error">unknown user</div>
+								</form>
+							</body>
+						</html>
+					`,
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:       "test-client",
+				RedirectURI:    "http://localhost:9000/auth-callback",
+				Scopes:         "openid profile email",
+				Username:       "foo",
+				Password:       "bar",
+				UsernameField:  "#user",
+				PasswordField:  "#pass",
+				SubmitButton:   "#submit",
+				UsernameSubmit: "#next",
+				ErrorMessage:   "#error",
+			},
+			wantErr: errors.New(errors.KindLoginError),
+		},
+		{
+			name: "login script",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html: `
+						<html>
+							<body>
+								<div id="banner"><button id="accept-cookies" onclick="this.parentNode.remove()">ok</button></div>
+								<form method="post" action="/handle-auth">
+									<select id="tenant" name="tenant">
+										<option value="default">default</option>
+										<option value="acme">acme</option>
+									</select>
+									<input id="user" name="user"/>
+									<input id="pass" name="pass"/>
+									<input id="remember" name="remember" type="checkbox" value="yes"/>
+									<button id="submit">login</button>
+								</form>
+							</body>
+						</html>
+					`,
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
+					wantBody: map[string]interface{}{"tenant": "acme", "user": "foo", "pass": "bar", "remember": "yes"},
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				Script: &Script{Steps: []*Step{
+					{Action: ActionBranchIfPresent, Selector: "#banner", Then: []*Step{{Action: ActionClick, Selector: "#accept-cookies"}}},
+					{Action: ActionSelect, Selector: "#tenant", Value: "acme"},
+					{Action: ActionFill, Selector: "#user", Value: "${username}"},
+					{Action: ActionFill, Selector: "#pass", Value: "${password}"},
+					{Action: ActionCheck, Selector: "#remember"},
+					{Action: ActionClick, Selector: "#submit", Wait: true},
+				}},
+			},
+			wantAccToken: "access_token_value",
+			wantIDToken:  "{id_token}",
+			chromeOnly:   true,
+		},
+		{
+			name: "login script: step failed",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				Script: &Script{Steps: []*Step{
+					{Action: ActionAssertText, Selector: "#submit", Text: "sign in"},
+				}},
+			},
+			wantErr:    errors.New(errors.KindScriptStepFailed),
+			chromeOnly: true,
+		},
+		{
+			name: "second factor",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:     "/handle-auth",
+					status:   http.StatusOK,
+					html:     htmlOTPForm("/handle-otp"),
+					wantBody: map[string]interface{}{"user": "foo", "pass": "bar"},
+				},
+				{
+					path:     "/handle-otp",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
+					wantBody: map[string]interface{}{"otp": totpCode},
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				TOTP:          TOTP{Secret: totpSecret, Field: "#otp", Submit: "#otp-submit"},
+			},
+			wantAccToken: "access_token_value",
+			wantIDToken:  "{id_token}",
+		},
+		{
+			name: "second factor: code is rejected",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:   "/handle-auth",
+					status: http.StatusOK,
+					html:   htmlOTPForm("/handle-otp"),
+				},
+				{
+					path:   "/handle-otp",
+					status: http.StatusOK,
+					html:   htmlOTPForm("/handle-otp"),
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				TOTP:          TOTP{Secret: totpSecret, Field: "#otp", Submit: "#otp-submit"},
+			},
+			wantErr: errors.New(errors.KindOTPRejected),
+		},
+		{
+			name: "second factor: invalid secret",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:9000/auth-callback",
+				Scopes:        "openid profile email",
+				Username:      "foo",
+				Password:      "bar",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
+				TOTP:          TOTP{Secret: "not base32!", Field: "#otp", Submit: "#otp-submit"},
+			},
+			wantErr: errors.New(errors.KindOTPSecretInvalid),
+		},
+		{
+			name: "consent page: approve a subset of scopes",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:   "/handle-auth",
+					status: http.StatusOK,
+					html:   htmlConsentForm("/handle-consent", "openid", "profile", "email"),
+				},
+				{
+					path:     "/handle-consent",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
+					wantBody: map[string]interface{}{"grant_scope": "openid", "submit": "accept"},
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:       "test-client",
+				RedirectURI:    "http://localhost:9000/auth-callback",
+				Scopes:         "openid profile email",
+				Username:       "foo",
+				Password:       "bar",
+				UsernameField:  "#user",
+				PasswordField:  "#pass",
+				SubmitButton:   "#submit",
+				ErrorMessage:   "#error",
+				ConsentScope:   "input[name=grant_scope]",
+				ConsentApprove: "#accept",
+				ConsentDeny:    "#reject",
+				GrantScopes:    "openid",
+			},
+			wantAccToken: "access_token_value",
+			wantIDToken:  "{id_token}",
+		},
+		{
+			name: "consent page: scope is not offered",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:   "/handle-auth",
+					status: http.StatusOK,
+					html:   htmlConsentForm("/handle-consent", "openid"),
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:       "test-client",
+				RedirectURI:    "http://localhost:9000/auth-callback",
+				Scopes:         "openid profile email",
+				Username:       "foo",
+				Password:       "bar",
+				UsernameField:  "#user",
+				PasswordField:  "#pass",
+				SubmitButton:   "#submit",
+				ErrorMessage:   "#error",
+				ConsentScope:   "input[name=grant_scope]",
+				ConsentApprove: "#accept",
+				ConsentDeny:    "#reject",
+				GrantScopes:    "email",
+			},
+			wantErr: errors.New(errors.KindConsentScopeInvalid),
+		},
+		{
+			name: "consent page: deny",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/auth",
+					status: http.StatusOK,
+					html:   htmlForm("/handle-auth"),
+				},
+				{
+					path:   "/handle-auth",
+					status: http.StatusOK,
+					html:   htmlConsentForm("/handle-consent", "openid", "profile", "email"),
+				},
+				{
+					path:     "/handle-consent",
+					status:   http.StatusPermanentRedirect,
+					redirect: "http://localhost:3000#error=access_denied&error_description=The+resource+owner+denied+the+request&state={state}",
+					wantBody: map[string]interface{}{"submit": "reject"},
+				},
+			},
+			cnf: &LoginConfig{
+				ClientID:       "test-client",
+				RedirectURI:    "http://localhost:9000/auth-callback",
+				Scopes:         "openid profile email",
+				Username:       "foo",
+				Password:       "bar",
+				UsernameField:  "#user",
+				PasswordField:  "#pass",
+				SubmitButton:   "#submit",
+				ErrorMessage:   "#error",
+				ConsentScope:   "input[name=grant_scope]",
+				ConsentApprove: "#accept",
+				ConsentDeny:    "#reject",
+				DenyConsent:    true,
+			},
+			wantErr: errors.New(errors.KindOIDCError),
 		},
 		{
 			name: "password from stdin",
@@ -383,7 +890,7 @@ func TestLogin(t *testing.T) {
 				{
 					path:     "/handle-auth",
 					status:   http.StatusPermanentRedirect,
-					redirect: "http://localhost:3000#access_token=access_token_value&id_token=id_token_value",
+					redirect: "http://localhost:3000#access_token=access_token_value&id_token={id_token}&state={state}",
 					wantBody: map[string]interface{}{"user": "foo", "pass": "bar"},
 				},
 			},
@@ -400,118 +907,356 @@ func TestLogin(t *testing.T) {
 				ErrorMessage:  "#error",
 			},
 			wantAccToken: "access_token_value",
-			wantIDToken:  "id_token_value",
+			wantIDToken:  "{id_token}",
 		},
 	}
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			cnf := tc.cnf
-			if cnf == nil {
-				cnf = &LoginConfig{}
-			}
-			if tc.endpoints != nil {
-				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					var (
-						ep       endpoint
-						epExists bool
-					)
-					for _, v := range tc.endpoints {
-						if v.path == r.URL.Path {
-							ep = v
-							epExists = true
-							break
+	// Each case is run by every engine.
+	for _, engine := range []string{EngineChrome, EngineHTTP} {
+		for _, tc := range testCases {
+			t.Run(engine+"/"+tc.name, func(t *testing.T) {
+				if engine == EngineHTTP && tc.chromeOnly {
+					t.Skip("the case is not supported by the engine http")
+				}
+				cnf := &LoginConfig{}
+				if tc.cnf != nil {
+					v := *tc.cnf
+					cnf = &v
+				}
+				if cnf.Engine == "" {
+					cnf.Engine = engine
+				}
+				// The state and nonce of the authentication request are random,
+				// so the test server remembers them to build the authentication callback.
+				var state, nonce string
+				expand := func(s string) string {
+					return strings.NewReplacer(
+						"{state}", state,
+						"{id_token}", testIDToken(nonce),
+						"{foreign_id_token}", testIDToken("foreign-nonce"),
+					).Replace(s)
+				}
+				if tc.endpoints != nil {
+					srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if r.URL.Path == "/.well-known/openid-configuration" {
+							issuer := "http://" + r.Host
+							w.Header().Set("Content-Type", "application/json")
+							fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q, "token_endpoint": %q}`, issuer, issuer+"/oauth2/auth", issuer+"/oauth2/token")
+							return
 						}
-					}
-					if !epExists {
-						http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-						return
-					}
 
-					if ep.wantQuery != nil {
-						query := make(map[string]interface{})
-						for param := range r.URL.Query() {
-							query[param] = r.URL.Query().Get(param)
+						var (
+							ep       endpoint
+							epExists bool
+						)
+						for _, v := range tc.endpoints {
+							if v.path == r.URL.Path {
+								ep = v
+								epExists = true
+								break
+							}
 						}
-						if !reflect.DeepEqual(query, ep.wantQuery) {
-							t.Fatalf("got query %#v, want query: %#v", query, ep.wantQuery)
+						if !epExists {
+							http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+							return
+						}
+
+						if r.URL.Path == "/oauth2/auth" {
+							state, nonce = r.URL.Query().Get("state"), r.URL.Query().Get("nonce")
 						}
-					}
 
-					if ep.wantBody != nil {
-						var body map[string]interface{}
-						if r.Body != http.NoBody {
-							b, err := ioutil.ReadAll(r.Body)
-							if err != nil {
-								t.Fatalf("failed to decode login form's data: %s", err)
+						if ep.wantQuery != nil {
+							query := make(map[string]interface{})
+							for param := range r.URL.Query() {
+								query[param] = r.URL.Query().Get(param)
+								if ep.wantQuery[param] == anyValue && query[param] != "" {
+									query[param] = anyValue
+								}
+							}
+							if !reflect.DeepEqual(query, ep.wantQuery) {
+								t.Fatalf("got query %#v, want query: %#v", query, ep.wantQuery)
 							}
-							q, err := url.ParseQuery(string(b))
-							if err != nil {
-								t.Fatalf("failed to decode login form's data: %s", err)
+						}
+
+						if ep.wantBody != nil {
+							var body map[string]interface{}
+							if r.Body != http.NoBody {
+								b, err := ioutil.ReadAll(r.Body)
+								if err != nil {
+									t.Fatalf("failed to decode login form's data: %s", err)
+								}
+								q, err := url.ParseQuery(string(b))
+								if err != nil {
+									t.Fatalf("failed to decode login form's data: %s", err)
+								}
+								body = make(map[string]interface{})
+								for key := range q {
+									body[key] = q.Get(key)
+								}
 							}
-							body = make(map[string]interface{})
-							for key := range q {
-								body[key] = q.Get(key)
+							if !reflect.DeepEqual(body, ep.wantBody) {
+								t.Fatalf("got form data %#v, want form data: %#v", body, ep.wantBody)
 							}
 						}
-						if !reflect.DeepEqual(body, ep.wantBody) {
-							t.Fatalf("got form data %#v, want form data: %#v", body, ep.wantBody)
+
+						if ep.status >= 300 && ep.status < 400 {
+							http.Redirect(w, r, expand(ep.redirect), ep.status)
+							return
+						}
+						if ep.json != "" {
+							w.Header().Set("Content-Type", "application/json")
+							w.WriteHeader(ep.status)
+							fmt.Fprintln(w, expand(ep.json))
+							return
+						}
+						w.Header().Set("Content-Type", "text/html")
+						w.WriteHeader(ep.status)
+						fmt.Fprintln(w, ep.html)
+					}))
+					defer srv.Close()
+
+					cnf.Endpoint = srv.URL
+					if testServerHost != "" {
+						u, err := url.Parse(srv.URL)
+						if err != nil {
+							t.Fatalf("failed to parse test endpoint's URL: %s", err)
 						}
+						u.Host = fmt.Sprintf("%s:%s", testServerHost, u.Port())
+						cnf.Endpoint = u.String()
 					}
+				}
+				if cnf.PasswordStdin {
+					v := pwdFromStdin
+					pwdFromStdin = func() (string, error) { return cnf.Password, nil }
+					defer func() { pwdFromStdin = v }()
+				}
+
+				ctx := context.Background()
+				if verbose {
+					ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+				}
+				got, err := Login(ctx, remoteChromeURL, cnf)
 
-					if ep.status >= 300 && ep.status < 400 {
-						http.Redirect(w, r, ep.redirect, ep.status)
-						return
+				if tc.wantErr != nil {
+					if err == nil {
+						t.Fatalf("\ngot no errors\nwant error:\n\t%s", errStr(tc.wantErr))
 					}
-					w.Header().Set("Content-Type", "text/html")
-					w.WriteHeader(ep.status)
-					fmt.Fprintln(w, ep.html)
-				}))
-				defer srv.Close()
-
-				cnf.Endpoint = srv.URL
-				if testServerHost != "" {
-					u, err := url.Parse(srv.URL)
-					if err != nil {
-						t.Fatalf("failed to parse test endpoint's URL: %s", err)
+					if !errors.Match(err, tc.wantErr) {
+						t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", errStr(err), errStr(tc.wantErr))
 					}
-					u.Host = fmt.Sprintf("%s:%s", testServerHost, u.Port())
-					cnf.Endpoint = u.String()
+					return
 				}
+
+				if err != nil {
+					t.Fatalf("\ngot error:\n\t%s\nwant no errors", errStr(err))
+				}
+
+				want := &LoginData{
+					AccessToken:  tc.wantAccToken,
+					IDToken:      expand(tc.wantIDToken),
+					RefreshToken: tc.wantRefToken,
+					ExpiresIn:    tc.wantExpires,
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("got %#v, want %#v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestLoginWithSession(t *testing.T) {
+	var (
+		verbose         = os.Getenv("TOKGET_TEST_VERBOSE") == "true"
+		remoteChromeURL = os.Getenv("TOKGET_TEST_REMOTE_CHROME")
+		testServerHost  = os.Getenv("TOKGET_TEST_SERVER_HOST")
+	)
+
+	// The login page sets a cookie, and fails when a request already contains it,
+	// so a login fails when cookies leak between the session's tabs.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			issuer := "http://" + r.Host
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"issuer": %q, "authorization_endpoint": %q}`, issuer, issuer+"/oauth2/auth")
+		case "/oauth2/auth":
+			if _, err := r.Cookie("login_session"); err == nil {
+				http.Redirect(w, r, "/error?error=cookie_leaked", http.StatusFound)
+				return
 			}
-			if cnf.PasswordStdin {
-				v := pwdFromStdin
-				pwdFromStdin = func() (string, error) { return cnf.Password, nil }
-				defer func() { pwdFromStdin = v }()
-			}
+			http.SetCookie(w, &http.Cookie{Name: "login_session", Value: r.URL.Query().Get("state")})
+			q := url.Values{}
+			q.Set("state", r.URL.Query().Get("state"))
+			q.Set("nonce", r.URL.Query().Get("nonce"))
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, htmlForm("/handle-auth?"+q.Encode()))
+		case "/handle-auth":
+			q := r.URL.Query()
+			fragment := url.Values{}
+			fragment.Set("access_token", r.FormValue("user"))
+			fragment.Set("id_token", testIDToken(q.Get("nonce")))
+			fragment.Set("state", q.Get("state"))
+			http.Redirect(w, r, "http://localhost:3000#"+fragment.Encode(), http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintln(w, "<html><body></body></html>")
+		}
+	}))
+	defer srv.Close()
+	endpoint := srv.URL
+	if testServerHost != "" {
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to parse test endpoint's URL: %s", err)
+		}
+		u.Host = fmt.Sprintf("%s:%s", testServerHost, u.Port())
+		endpoint = u.String()
+	}
+
+	ctx := context.Background()
+	if verbose {
+		ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+	}
+	session, err := chrome.NewSession(ctx, remoteChromeURL)
+	if err != nil {
+		t.Fatalf("failed to create Chrome session: %s", err)
+	}
+	defer session.Close()
+	ctx = chrome.WithSession(ctx, session)
 
-			ctx := context.Background()
-			if verbose {
-				ctx = log.WithDebugger(ctx, log.VerboseDebugger)
+	// Each user logs in twice to check that the second login in the session does not see cookies of the first one.
+	users := []string{"alice", "bob", "carol", "alice", "bob", "carol"}
+	var wg sync.WaitGroup
+	errs := make([]error, len(users))
+	tokens := make([]string, len(users))
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+			cnf := &LoginConfig{
+				Endpoint:      endpoint,
+				ClientID:      "test-client",
+				RedirectURI:   "http://localhost:3000",
+				Scopes:        "openid",
+				Username:      user,
+				Password:      "secret",
+				UsernameField: "#user",
+				PasswordField: "#pass",
+				SubmitButton:  "#submit",
+				ErrorMessage:  "#error",
 			}
-			got, err := Login(ctx, remoteChromeURL, cnf)
+			data, err := Login(ctx, "", cnf)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			tokens[i] = data.AccessToken
+		}(i, user)
+	}
+	wg.Wait()
+
+	for i, user := range users {
+		if errs[i] != nil {
+			t.Fatalf("login %d (%s): got error:\n\t%s\nwant no errors", i+1, user, errs[i])
+		}
+		if tokens[i] != user {
+			t.Fatalf("login %d (%s): got access token %q, want %q", i+1, user, tokens[i], user)
+		}
+	}
+}
+
+func TestExtractLoginData(t *testing.T) {
+	implicitReq := &authRequest{redirectURI: "http://localhost:3000/cb", state: "state-1", nonce: "nonce-1"}
+	codeReq := &authRequest{redirectURI: "http://localhost:3000/cb", state: "state-1", nonce: "nonce-1", codeVerifier: "verifier"}
+
+	testCases := []struct {
+		name    string
+		url     string
+		req     *authRequest
+		want    *LoginData
+		wantErr error
+	}{
+		{
+			name: "not a callback",
+			url:  "http://op/login",
+			req:  implicitReq,
+		},
+		{
+			name:    "implicit flow: state mismatch",
+			url:     "http://localhost:3000/cb#access_token=at&id_token=" + testIDToken("nonce-1") + "&state=state-2",
+			req:     implicitReq,
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+		{
+			name:    "implicit flow: state is missed",
+			url:     "http://localhost:3000/cb#access_token=at&id_token=" + testIDToken("nonce-1"),
+			req:     implicitReq,
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+		{
+			name:    "implicit flow: nonce mismatch",
+			url:     "http://localhost:3000/cb#access_token=at&id_token=" + testIDToken("nonce-2") + "&state=state-1",
+			req:     implicitReq,
+			wantErr: errors.New(errors.KindNonceMismatch),
+		},
+		{
+			name: "implicit flow: happy path",
+			url:  "http://localhost:3000/cb#access_token=at&id_token=" + testIDToken("nonce-1") + "&state=state-1&expires_in=60",
+			req:  implicitReq,
+			want: &LoginData{AccessToken: "at", IDToken: testIDToken("nonce-1"), ExpiresIn: 60},
+		},
+		{
+			name:    "implicit flow: openid connect error",
+			url:     "http://localhost:3000/cb#error=access_denied&error_description=denied&state=state-1",
+			req:     implicitReq,
+			wantErr: errors.New(errors.KindOIDCError),
+		},
+		{
+			name: "code flow: not a callback",
+			url:  "http://op/login?code=code",
+			req:  codeReq,
+		},
+		{
+			name:    "code flow: state mismatch",
+			url:     "http://localhost:3000/cb?code=code&state=state-2",
+			req:     codeReq,
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := extractLoginData(context.Background(), tc.url, &ProviderMetadata{}, tc.req)
 
 			if tc.wantErr != nil {
 				if err == nil {
-					t.Fatalf("\ngot no errors\nwant error:\n\t%s", errStr(tc.wantErr))
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
 				}
 				if !errors.Match(err, tc.wantErr) {
-					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", errStr(err), errStr(tc.wantErr))
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
 				}
 				return
 			}
-
 			if err != nil {
-				t.Fatalf("\ngot error:\n\t%s\nwant no errors", errStr(err))
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
 			}
-
-			want := &LoginData{AccessToken: tc.wantAccToken, IDToken: tc.wantIDToken}
-			if !reflect.DeepEqual(got, want) {
-				t.Fatalf("got %#v, want %#v", got, want)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
 			}
 		})
 	}
 }
 
+// anyValue is a value of an expected query parameter that matches any non-empty value.
+const anyValue = "<any value>"
+
+// testIDToken returns an unsigned ID token with a nonce.
+func testIDToken(nonce string) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"sub":"foo","nonce":%q}`, nonce)))
+	return header + "." + claims + "."
+}
+
 func htmlForm(action string) string {
 	return `
 		<html>
@@ -526,6 +1271,63 @@ func htmlForm(action string) string {
 	`
 }
 
+func htmlUsernameForm(action string) string {
+	return `
+		<html>
+			<body>
+				<form method="post" action="` + action + `">
+					<input id="user" name="user"/>
+					<button id="next">next</button>
+				</form>
+			</body>
+		</html>
+	`
+}
+
+func htmlPasswordForm(action string) string {
+	return `
+		<html>
+			<body>
+				<form method="post" action="` + action + `">
+					<input id="pass" name="pass"/>
+					<button id="submit">login</button>
+				</form>
+			</body>
+		</html>
+	`
+}
+
+func htmlOTPForm(action string) string {
+	return `
+		<html>
+			<body>
+				<form method="post" action="` + action + `">
+					<input id="otp" name="otp"/>
+					<button id="otp-submit">verify</button>
+				</form>
+			</body>
+		</html>
+	`
+}
+
+func htmlConsentForm(action string, scopes ...string) string {
+	var boxes string
+	for _, scope := range scopes {
+		boxes += `<input type="checkbox" name="grant_scope" value="` + scope + `"/>`
+	}
+	return `
+		<html>
+			<body>
+				<form method="post" action="` + action + `">
+					` + boxes + `
+					<button id="accept" name="submit" value="accept">allow</button>
+					<button id="reject" name="submit" value="reject">deny</button>
+				</form>
+			</body>
+		</html>
+	`
+}
+
 func htmlFormWithError(action, err string) string {
 	return `
 		<html>
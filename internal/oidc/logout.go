@@ -9,7 +9,9 @@ package oidc
 import (
 	"context"
 	"net/url"
+	"time"
 
+	"github.com/chromedp/chromedp"
 	"github.com/i-core/tokget/internal/chrome"
 	"github.com/i-core/tokget/internal/errors"
 	"github.com/i-core/tokget/internal/log"
@@ -17,11 +19,31 @@ import (
 
 // LogoutConfig is a configuration of the logout process.
 type LogoutConfig struct {
-	Endpoint string // an OpenID Connect endpoint
-	IDToken  string // an ID token
+	Endpoint              string           // an OpenID Connect endpoint
+	Metadata              ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	IDToken               string           // an ID token
+	ClientID              string           // a client's ID; it is sent to the end session endpoint, and required to revoke tokens
+	PostLogoutRedirectURI string           // a client's URL to redirect a user to after the logout
+	LogoutHint            string           // a hint about the user who is logging out, for example, a user's name
+	UILocales             string           // preferred languages of the logout pages, space-separated
+	ConfirmButton         string           // a CSS selector of the confirm button on the logout confirmation page
+	Revoke                bool             // revoke the access token and refresh token before the logout
+	ClientAuth            ClientAuth       // a client's authentication at the revocation endpoint
+	AccessToken           string           // an access token to revoke
+	RefreshToken          string           // a refresh token to revoke
 }
 
 // Logout logs a user out and revoke the specified ID token.
+//
+// When the configuration enables the revocation the access token and refresh token are revoked
+// at the revocation endpoint before the logout, so the tokens are invalidated even if the logout page fails.
+//
+// When the OpenID Connect Provider shows the logout confirmation page (Keycloak does it by default)
+// the function confirms the logout by clicking the confirm button. When the configuration defines
+// the post logout redirect URI the function checks that the provider redirected a user to it
+// with the logout request's state, so a logout that silently did nothing is reported as an error.
+//
+// See https://openid.net/specs/openid-connect-rpinitiated-1_0.html.
 func Logout(ctx context.Context, chromeURL string, cnf *LogoutConfig) error {
 	//
 	// Step 1. Validate input parameters.
@@ -29,13 +51,54 @@ func Logout(ctx context.Context, chromeURL string, cnf *LogoutConfig) error {
 	if cnf.Endpoint == "" {
 		return errors.New(errors.KindEndpointMissed, "OpenID Connect endpoint is missed")
 	}
-	endpoint, err := url.Parse(cnf.Endpoint)
+	_, err := url.Parse(cnf.Endpoint)
 	if err != nil {
 		return errors.New(errors.KindEndpointInvalid, "OpenID Connect endpoint has an invalid value")
 	}
 	if cnf.IDToken == "" {
 		return errors.New(errors.KindIDTokenMissed, "ID token is missed")
 	}
+	required := []endpoint{endpointEndSession}
+	if cnf.Revoke {
+		if cnf.ClientID == "" {
+			return errors.New(errors.KindClientIDMissed, "client ID is missed")
+		}
+		if cnf.AccessToken == "" && cnf.RefreshToken == "" {
+			return errors.New(errors.KindTokenMissed, "neither access token nor refresh token to revoke is defined")
+		}
+		if err = cnf.ClientAuth.validate(); err != nil {
+			return err
+		}
+		required = append(required, endpointRevocation)
+	}
+
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, required...)
+	if err != nil {
+		return err
+	}
+
+	if cnf.Revoke {
+		// The refresh token is revoked first because of revoking it can revoke the access tokens issued by it too.
+		for _, token := range []struct{ value, hint string }{
+			{cnf.RefreshToken, TokenTypeRefresh},
+			{cnf.AccessToken, TokenTypeAccess},
+		} {
+			if token.value == "" {
+				continue
+			}
+			err = Revoke(ctx, &RevokeConfig{
+				Endpoint:      cnf.Endpoint,
+				Metadata:      *meta,
+				ClientID:      cnf.ClientID,
+				ClientAuth:    cnf.ClientAuth,
+				Token:         token.value,
+				TokenTypeHint: token.hint,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
 
 	//
 	// Step 2. Initialize Chrome connection.
@@ -54,7 +117,14 @@ func Logout(ctx context.Context, chromeURL string, cnf *LogoutConfig) error {
 	//
 	// Step 3. Navigate to the OpenID Connect Provider's logout page, and process result.
 	//
-	logoutURL := buildLogoutURL(endpoint, cnf.IDToken)
+	state, err := randomString(16)
+	if err != nil {
+		return errors.Wrap(err, "generate state")
+	}
+	logoutURL, err := buildLogoutURL(meta.EndSessionEndpoint, cnf, state)
+	if err != nil {
+		return err
+	}
 	debugger := log.DebuggerFromContext(ctx)
 	debugger.Debugf("Navigate to the logout page %q\n", logoutURL)
 	if err = chrome.Navigate(ctx, logoutURL); err != nil {
@@ -63,19 +133,91 @@ func Logout(ctx context.Context, chromeURL string, cnf *LogoutConfig) error {
 	if err = extractOIDCError(navHistory.Last()); err != nil {
 		return err
 	}
+
+	//
+	// Step 4. Confirm the logout if the OpenID Connect Provider shows the logout confirmation page.
+	//
+	if cnf.ConfirmButton != "" {
+		if err = confirmLogout(ctx, cnf.ConfirmButton); err != nil {
+			return err
+		}
+		if err = extractOIDCError(navHistory.Last()); err != nil {
+			return err
+		}
+	}
+
+	//
+	// Step 5. Check that the OpenID Connect Provider redirected a user to the post logout redirect URI.
+	//
+	if cnf.PostLogoutRedirectURI != "" {
+		if err = checkPostLogoutRedirect(navHistory.Last(), cnf.PostLogoutRedirectURI, state); err != nil {
+			return err
+		}
+	}
 	debugger.Debugln(`Logged out`)
 	return nil
 }
 
-func buildLogoutURL(endpoint *url.URL, idToken string) string {
-	ref, err := url.Parse("/oauth2/sessions/logout")
+// confirmLogout clicks the confirm button when the current page is the logout confirmation page,
+// and waits for loading of the next page. The function does nothing when the page does not contain the button.
+func confirmLogout(ctx context.Context, sel string) error {
+	has, err := chrome.HasElement(ctx, sel)
 	if err != nil {
-		panic(errors.Wrap(err, "make logout url"))
+		return errors.Wrap(err, "find the logout confirmation page's button")
+	}
+	if !has {
+		return nil
+	}
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugln("Confirm the logout")
+	wait := chrome.PageLoadWaiterFunc(ctx, false, 5*time.Second)
+	if err = chromedp.Run(ctx, chromedp.Click(sel)); err != nil {
+		return errors.Wrap(err, "submit the logout confirmation form")
+	}
+	if err = wait(); err != nil {
+		return errors.Wrap(err, "wait for submiting the logout confirmation form")
+	}
+	return nil
+}
+
+func buildLogoutURL(endSessionEndpoint string, cnf *LogoutConfig, state string) (string, error) {
+	loURL, err := url.Parse(endSessionEndpoint)
+	if err != nil {
+		return "", errors.New(errors.KindEndpointInvalid, "end session endpoint has an invalid value")
 	}
-	loURL := endpoint.ResolveReference(ref)
 	query := loURL.Query()
-	query.Set("id_token_hint", idToken)
-	query.Set("state", "12345678")
+	query.Set("id_token_hint", cnf.IDToken)
+	query.Set("state", state)
+	for name, value := range map[string]string{
+		"client_id":                cnf.ClientID,
+		"post_logout_redirect_uri": cnf.PostLogoutRedirectURI,
+		"logout_hint":              cnf.LogoutHint,
+		"ui_locales":               cnf.UILocales,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
 	loURL.RawQuery = query.Encode()
-	return loURL.String()
+	return loURL.String(), nil
+}
+
+// checkPostLogoutRedirect checks that the last navigation request after the logout is the post logout redirect URI
+// with the logout request's state.
+func checkPostLogoutRedirect(u, redirectURI, state string) error {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return errors.Wrap(err, "parse post logout URL")
+	}
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil {
+		return errors.Wrap(err, "parse post logout redirect uri")
+	}
+	if parsedURL.Scheme != redirectURL.Scheme || parsedURL.Host != redirectURL.Host || parsedURL.Path != redirectURL.Path {
+		return errors.New(errors.KindLogoutError, "the OpenID Connect Provider did not redirect to the post logout redirect uri, the last page is %q", u)
+	}
+	if got := parsedURL.Query().Get("state"); got != state {
+		return errors.New(errors.KindStateMismatch, "the post logout redirect's state %q does not match the logout request's state", got)
+	}
+	return nil
 }
@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/i-core/tokget/internal/errors"
@@ -30,6 +31,8 @@ func TestLogout(t *testing.T) {
 		testServerHost = os.Getenv("TOKGET_TEST_SERVER_HOST")
 	)
 
+	// An endpoint's redirect and html can contain the placeholder {state} that is replaced with
+	// the logout request's state.
 	type endpoint struct {
 		path      string
 		status    int
@@ -42,7 +45,9 @@ func TestLogout(t *testing.T) {
 		name      string
 		endpoints []endpoint
 		cnf       *LogoutConfig
-		wantErr   error
+		// postLogoutPath is a path of the test server that is used as the post logout redirect URI.
+		postLogoutPath string
+		wantErr        error
 	}{
 		{
 			name:    "endpoint is missed",
@@ -59,12 +64,48 @@ func TestLogout(t *testing.T) {
 			},
 			wantErr: errors.New(errors.KindIDTokenMissed),
 		},
+		{
+			name: "revocation without client id",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/sessions/logout",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf:     &LogoutConfig{IDToken: "id-token", Revoke: true, AccessToken: "access-token"},
+			wantErr: errors.New(errors.KindClientIDMissed),
+		},
+		{
+			name: "revocation without tokens",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/sessions/logout",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf:     &LogoutConfig{IDToken: "id-token", Revoke: true, ClientID: "test-client"},
+			wantErr: errors.New(errors.KindTokenMissed),
+		},
+		{
+			name: "revocation error",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/revoke",
+					status: http.StatusUnauthorized,
+					html:   `{"error": "invalid_client"}`,
+				},
+			},
+			cnf:     &LogoutConfig{IDToken: "id-token", Revoke: true, ClientID: "test-client", RefreshToken: "refresh-token"},
+			wantErr: errors.New(errors.KindOIDCError),
+		},
 		{
 			name: "logout error",
 			endpoints: []endpoint{
 				{
 					path:      "/oauth2/sessions/logout",
-					wantQuery: map[string]interface{}{"id_token_hint": "id-token", "state": "12345678"},
+					wantQuery: map[string]interface{}{"id_token_hint": "id-token", "state": anyValue},
 					status:    http.StatusPermanentRedirect,
 					redirect:  "/error?error=logout error&error_description=logout error",
 				},
@@ -82,7 +123,7 @@ func TestLogout(t *testing.T) {
 			endpoints: []endpoint{
 				{
 					path:      "/oauth2/sessions/logout",
-					wantQuery: map[string]interface{}{"id_token_hint": "id-token", "state": "12345678"},
+					wantQuery: map[string]interface{}{"id_token_hint": "id-token", "state": anyValue},
 					status:    http.StatusPermanentRedirect,
 					redirect:  "/post-logout",
 				},
@@ -94,6 +135,82 @@ func TestLogout(t *testing.T) {
 			},
 			cnf: &LogoutConfig{IDToken: "id-token"},
 		},
+		{
+			name: "redirect to post logout redirect uri",
+			endpoints: []endpoint{
+				{
+					path: "/oauth2/sessions/logout",
+					wantQuery: map[string]interface{}{
+						"id_token_hint":            "id-token",
+						"state":                    anyValue,
+						"client_id":                "test-client",
+						"post_logout_redirect_uri": anyValue,
+						"logout_hint":              "foo",
+						"ui_locales":               "ru en",
+					},
+					status:   http.StatusFound,
+					redirect: "/post-logout?state={state}",
+				},
+				{
+					path:   "/post-logout",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf:            &LogoutConfig{IDToken: "id-token", ClientID: "test-client", LogoutHint: "foo", UILocales: "ru en"},
+			postLogoutPath: "/post-logout",
+		},
+		{
+			name: "no redirect to post logout redirect uri",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/sessions/logout",
+					status: http.StatusOK,
+					html:   "<html><body><p>You are still logged in</p></body></html>",
+				},
+			},
+			cnf:            &LogoutConfig{IDToken: "id-token"},
+			postLogoutPath: "/post-logout",
+			wantErr:        errors.New(errors.KindLogoutError),
+		},
+		{
+			name: "state mismatch",
+			endpoints: []endpoint{
+				{
+					path:     "/oauth2/sessions/logout",
+					status:   http.StatusFound,
+					redirect: "/post-logout?state=foo",
+				},
+				{
+					path:   "/post-logout",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf:            &LogoutConfig{IDToken: "id-token"},
+			postLogoutPath: "/post-logout",
+			wantErr:        errors.New(errors.KindStateMismatch),
+		},
+		{
+			name: "logout confirmation page",
+			endpoints: []endpoint{
+				{
+					path:   "/oauth2/sessions/logout",
+					status: http.StatusOK,
+					html: `<html><body><form action="/post-logout">
+						<input type="hidden" name="state" value="{state}">
+						<button id="kc-logout" type="submit">Logout</button>
+					</form></body></html>`,
+				},
+				{
+					path:   "/post-logout",
+					status: http.StatusOK,
+					html:   "<html><body></body></html>",
+				},
+			},
+			cnf:            &LogoutConfig{IDToken: "id-token", ConfirmButton: "#kc-logout"},
+			postLogoutPath: "/post-logout",
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -103,6 +220,14 @@ func TestLogout(t *testing.T) {
 			}
 			if len(tc.endpoints) > 0 {
 				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/.well-known/openid-configuration" {
+						issuer := "http://" + r.Host
+						w.Header().Set("Content-Type", "application/json")
+						fmt.Fprintf(w, `{"issuer": %q, "end_session_endpoint": %q, "revocation_endpoint": %q}`,
+							issuer, issuer+"/oauth2/sessions/logout", issuer+"/oauth2/revoke")
+						return
+					}
+
 					var (
 						ep       endpoint
 						epExists bool
@@ -123,19 +248,24 @@ func TestLogout(t *testing.T) {
 						query := make(map[string]interface{})
 						for param := range r.URL.Query() {
 							query[param] = r.URL.Query().Get(param)
+							if ep.wantQuery[param] == anyValue && query[param] != "" {
+								query[param] = anyValue
+							}
 						}
 						if !reflect.DeepEqual(query, ep.wantQuery) {
 							t.Fatalf("got query %#v, want query: %#v", query, ep.wantQuery)
 						}
 					}
 
+					// The logout request's state is passed to the next pages.
+					placeholders := strings.NewReplacer("{state}", r.URL.Query().Get("state"))
 					if ep.status >= 300 && ep.status < 400 {
-						http.Redirect(w, r, ep.redirect, ep.status)
+						http.Redirect(w, r, placeholders.Replace(ep.redirect), ep.status)
 						return
 					}
 					w.Header().Set("Content-Type", "text/html")
 					w.WriteHeader(ep.status)
-					fmt.Fprintln(w, ep.html)
+					fmt.Fprintln(w, placeholders.Replace(ep.html))
 				}))
 				defer srv.Close()
 
@@ -148,6 +278,9 @@ func TestLogout(t *testing.T) {
 					u.Host = fmt.Sprintf("%s:%s", testServerHost, u.Port())
 					cnf.Endpoint = u.String()
 				}
+				if tc.postLogoutPath != "" {
+					cnf.PostLogoutRedirectURI = cnf.Endpoint + tc.postLogoutPath
+				}
 			}
 			ctx := context.Background()
 			if verbose {
@@ -171,3 +304,56 @@ func TestLogout(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckPostLogoutRedirect(t *testing.T) {
+	const redirectURI = "http://localhost:3000/logged-out"
+
+	testCases := []struct {
+		name    string
+		u       string
+		wantErr error
+	}{
+		{
+			name:    "not redirected",
+			u:       "https://openid-connect-provider/logout?state=12345678",
+			wantErr: errors.New(errors.KindLogoutError),
+		},
+		{
+			name:    "another path",
+			u:       "http://localhost:3000/?state=12345678",
+			wantErr: errors.New(errors.KindLogoutError),
+		},
+		{
+			name:    "state is missed",
+			u:       "http://localhost:3000/logged-out",
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+		{
+			name:    "state mismatch",
+			u:       "http://localhost:3000/logged-out?state=87654321",
+			wantErr: errors.New(errors.KindStateMismatch),
+		},
+		{
+			name: "redirected",
+			u:    "http://localhost:3000/logged-out?state=12345678",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPostLogoutRedirect(tc.u, redirectURI, "12345678")
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+		})
+	}
+}
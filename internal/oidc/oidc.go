@@ -7,8 +7,16 @@ LICENSE file in the root directory of this source tree.
 package oidc
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/i-core/tokget/internal/errors"
 )
@@ -20,6 +28,9 @@ import (
 // When the query parameter "error" is not empty, the function returns an error with a message equals to
 // the query parameter "error_description".
 //
+// In the implicit flow the OpenID Connect Provider sends an error in the url's fragment
+// so the function looks for the error in the fragment too.
+//
 // Ory Hydra server responds with an error url that also contains query parameter "error_hint". The function
 // includes a value of the parameter to an error's message when the value is not empty.
 func extractOIDCError(u string) error {
@@ -27,13 +38,79 @@ func extractOIDCError(u string) error {
 	if err != nil {
 		return errors.Wrap(err, "parse post login url")
 	}
-	if qerr := parsedURL.Query().Get("error"); qerr == "" {
+	params := parsedURL.Query()
+	if params.Get("error") == "" && parsedURL.Fragment != "" {
+		if fragment, ferr := url.ParseQuery(parsedURL.Fragment); ferr == nil {
+			params = fragment
+		}
+	}
+	if qerr := params.Get("error"); qerr == "" {
 		return nil
 	}
-	msg := parsedURL.Query().Get("error_description")
+	msg := params.Get("error_description")
+	if msg == "" {
+		msg = params.Get("error")
+	}
 	// error_hint is sent by ORY Hydra Server only.
-	if hint := parsedURL.Query().Get("error_hint"); hint != "" {
+	if hint := params.Get("error_hint"); hint != "" {
 		msg = fmt.Sprintf("%s: %s", msg, hint)
 	}
 	return errors.New(errors.KindOIDCError, msg)
 }
+
+// randomString returns a base64url-encoded string of n cryptographically random bytes.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generate random bytes")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// fetch sends a GET request to an URL and returns the response's body.
+//
+// The function returns an error when the response's status code is not 200 OK.
+func fetch(ctx context.Context, u string, header http.Header) ([]byte, error) {
+	r, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	reqCtx, cancelReqCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelReqCtx()
+	resp, err := http.DefaultClient.Do(r.WithContext(reqCtx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("status code %d", resp.StatusCode)
+	}
+	return b, nil
+}
+
+// readSecret returns a secret that is taken from a value, from a file or from an environment variable, in the order.
+//
+// A secret from a file is trimmed of surrounding whitespaces. The function returns an empty string
+// when the secret is not defined.
+func readSecret(value, file, env string) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case file != "":
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrap(err, "read secret file")
+		}
+		return strings.TrimSpace(string(b)), nil
+	case env != "":
+		return os.Getenv(env), nil
+	}
+	return "", nil
+}
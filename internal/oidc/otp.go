@@ -0,0 +1,141 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+	"github.com/i-core/tokget/internal/totp"
+)
+
+// otpBoundary is a time before the end of a TOTP time step. When a code that is computed in this time is rejected,
+// the code of the next time step is tried because of the code can expire before the provider checks it.
+const otpBoundary = 5 * time.Second
+
+// TOTP is a configuration of the second factor by Time-Based One-Time Passwords (RFC 6238).
+type TOTP struct {
+	Secret     string // a shared secret in base32 format
+	SecretFile string // a path to a file that contains a shared secret
+	SecretEnv  string // a name of an environment variable that contains a shared secret
+	Field      string // a CSS selector of the one-time password field
+	Submit     string // a CSS selector of the submit button on the one-time password page
+}
+
+// enabled returns true when a shared secret is defined.
+func (t *TOTP) enabled() bool {
+	return t.Secret != "" || t.SecretFile != "" || t.SecretEnv != ""
+}
+
+// secret returns a decoded shared secret.
+//
+// The secret is taken from the configuration, from a file or from an environment variable, in the order.
+func (t *TOTP) secret() ([]byte, error) {
+	v, err := readSecret(t.Secret, t.SecretFile, t.SecretEnv)
+	if err != nil {
+		return nil, errors.New(errors.KindOTPSecretInvalid, err, "read TOTP secret")
+	}
+	if v == "" {
+		return nil, errors.New(errors.KindOTPSecretInvalid, "TOTP secret is empty")
+	}
+	b, err := totp.DecodeSecret(v)
+	if err != nil {
+		return nil, errors.New(errors.KindOTPSecretInvalid, err, "invalid TOTP secret")
+	}
+	return b, nil
+}
+
+// validate checks that the shared secret and the selectors of the one-time password page are defined.
+func (t *TOTP) validate() error {
+	if t.Field == "" {
+		return errors.New(errors.KindOTPFieldMissed, "one-time password field's selector is missed")
+	}
+	if t.Submit == "" {
+		return errors.New(errors.KindOTPSubmitMissed, "one-time password submit button's selector is missed")
+	}
+	_, err := t.secret()
+	return err
+}
+
+// passTOTP fills a one-time password when the current page is the one-time password page, and submits it.
+// The function does nothing when the page does not contain the one-time password field.
+//
+// When the provider rejects the code that is computed near the end of its time step, the function
+// waits for the next time step and retries once with the next code.
+func passTOTP(ctx context.Context, t *TOTP, errorMessage string) error {
+	debugger := log.DebuggerFromContext(ctx)
+
+	has, err := chrome.HasElement(ctx, t.Field)
+	if err != nil {
+		return errors.Wrap(err, "find the one-time password field")
+	}
+	if !has {
+		debugger.Debugln("The one-time password page is not shown")
+		return nil
+	}
+	secret, err := t.secret()
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	counter := totp.Counter(now)
+	debugger.Debugln("Submit the one-time password")
+	rejected, err := submitOTP(ctx, t, totp.Code(secret, counter))
+	if err != nil || !rejected {
+		return err
+	}
+	if totp.Remaining(now) > otpBoundary && totp.Counter(timeNow()) == counter {
+		return otpRejected(ctx, errorMessage)
+	}
+
+	if totp.Counter(timeNow()) == counter {
+		select {
+		case <-time.After(totp.Remaining(timeNow())):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	debugger.Debugln("The one-time password is rejected near the end of its time step; retry with the next one")
+	if rejected, err = submitOTP(ctx, t, totp.Code(secret, counter+1)); err != nil || !rejected {
+		return err
+	}
+	return otpRejected(ctx, errorMessage)
+}
+
+// submitOTP fills the one-time password field with a code, and submits it.
+// The function returns true when the page still contains the one-time password field after submitting.
+func submitOTP(ctx context.Context, t *TOTP, code string) (bool, error) {
+	// The field can contain a rejected code so it is cleared before filling.
+	if err := chromedp.Run(ctx, chromedp.SetValue(t.Field, ""), chromedp.SendKeys(t.Field, code)); err != nil {
+		return false, errors.Wrap(err, "fill the one-time password field")
+	}
+	wait := chrome.PageLoadWaiterFunc(ctx, false, 5*time.Second)
+	if err := chromedp.Run(ctx, chromedp.Click(t.Submit)); err != nil {
+		return false, errors.Wrap(err, "submit the one-time password")
+	}
+	if err := wait(); err != nil {
+		return false, errors.Wrap(err, "wait for submiting the one-time password")
+	}
+	has, err := chrome.HasElement(ctx, t.Field)
+	if err != nil {
+		return false, errors.Wrap(err, "find the one-time password field")
+	}
+	return has, nil
+}
+
+// otpRejected returns an error about a rejected one-time password.
+func otpRejected(ctx context.Context, errorMessage string) error {
+	if err := extractLoginError(ctx, errorMessage); err != nil {
+		return errors.New(errors.KindOTPRejected, "the one-time password is rejected: %s", err.Error())
+	}
+	return errors.New(errors.KindOTPRejected, "the one-time password is rejected")
+}
@@ -0,0 +1,67 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+// RefreshConfig is a configuration of the refresh process.
+type RefreshConfig struct {
+	Endpoint     string           // an OpenID Connect endpoint
+	Metadata     ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	ClientID     string           // a client's ID
+	ClientAuth   ClientAuth       // a client's authentication at the token endpoint
+	RefreshToken string           // a refresh token
+	Scopes       string           // OpenID Connect scopes; when it is empty the scopes of the original authentication are used
+}
+
+// Refresh exchanges a refresh token to new tokens at the token endpoint.
+//
+// The function does not need Google Chrome. It returns the same data as the login process.
+// When the OpenID Connect Provider does not rotate the refresh token the result contains the original refresh token.
+func Refresh(ctx context.Context, cnf *RefreshConfig) (*LoginData, error) {
+	if cnf.Endpoint == "" {
+		return nil, errors.New(errors.KindEndpointMissed, "OpenID Connect endpoint is missed")
+	}
+	if _, err := url.Parse(cnf.Endpoint); err != nil {
+		return nil, errors.New(errors.KindEndpointInvalid, "OpenID Connect endpoint has an invalid value")
+	}
+	if cnf.ClientID == "" {
+		return nil, errors.New(errors.KindClientIDMissed, "client ID is missed")
+	}
+	if cnf.RefreshToken == "" {
+		return nil, errors.New(errors.KindRefreshTokenMissed, "refresh token is missed")
+	}
+	if err := cnf.ClientAuth.validate(); err != nil {
+		return nil, err
+	}
+
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, endpointToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// See https://tools.ietf.org/html/rfc6749#section-6.
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", cnf.RefreshToken)
+	if cnf.Scopes != "" {
+		form.Set("scope", cnf.Scopes)
+	}
+	data, err := requestToken(ctx, meta.TokenEndpoint, cnf.ClientID, &cnf.ClientAuth, form)
+	if err != nil {
+		return nil, err
+	}
+	if data.RefreshToken == "" {
+		data.RefreshToken = cnf.RefreshToken
+	}
+	return data, nil
+}
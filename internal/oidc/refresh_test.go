@@ -0,0 +1,124 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestRefresh(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cnf      *RefreshConfig
+		resp     string
+		status   int
+		wantForm url.Values
+		want     *LoginData
+		wantErr  error
+	}{
+		{
+			name:    "refresh token is missed",
+			cnf:     &RefreshConfig{ClientID: "test-client"},
+			wantErr: errors.New(errors.KindRefreshTokenMissed),
+		},
+		{
+			name:    "client ID is missed",
+			cnf:     &RefreshConfig{RefreshToken: "refresh_token_value"},
+			wantErr: errors.New(errors.KindClientIDMissed),
+		},
+		{
+			name:    "refresh token is expired",
+			cnf:     &RefreshConfig{ClientID: "test-client", RefreshToken: "refresh_token_value"},
+			resp:    `{"error": "invalid_grant", "error_description": "refresh token is expired"}`,
+			status:  http.StatusBadRequest,
+			wantErr: errors.New(errors.KindOIDCError),
+		},
+		{
+			name: "refresh token is rotated",
+			cnf:  &RefreshConfig{ClientID: "test-client", RefreshToken: "refresh_token_value", Scopes: "openid offline_access"},
+			resp: `{
+				"access_token": "access_token_value",
+				"id_token": "id_token_value",
+				"refresh_token": "new_refresh_token_value",
+				"expires_in": 3600
+			}`,
+			status: http.StatusOK,
+			wantForm: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {"refresh_token_value"},
+				"scope":         {"openid offline_access"},
+				"client_id":     {"test-client"},
+			},
+			want: &LoginData{
+				AccessToken:  "access_token_value",
+				IDToken:      "id_token_value",
+				RefreshToken: "new_refresh_token_value",
+				ExpiresIn:    3600,
+			},
+		},
+		{
+			name:   "refresh token is not rotated",
+			cnf:    &RefreshConfig{ClientID: "test-client", RefreshToken: "refresh_token_value"},
+			resp:   `{"access_token": "access_token_value", "expires_in": 3600}`,
+			status: http.StatusOK,
+			wantForm: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {"refresh_token_value"},
+				"client_id":     {"test-client"},
+			},
+			want: &LoginData{
+				AccessToken:  "access_token_value",
+				RefreshToken: "refresh_token_value",
+				ExpiresIn:    3600,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Fatalf("failed to parse token request: %s", err)
+				}
+				if tc.wantForm != nil && !reflect.DeepEqual(r.PostForm, tc.wantForm) {
+					t.Fatalf("got form %#v, want form %#v", r.PostForm, tc.wantForm)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				fmt.Fprintln(w, tc.resp)
+			}))
+			defer srv.Close()
+
+			tc.cnf.Endpoint = srv.URL
+			tc.cnf.Metadata.TokenEndpoint = srv.URL
+			got, err := Refresh(context.Background(), tc.cnf)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/i-core/tokget/internal/log"
+)
+
+// RevokeConfig is a configuration of the token revocation.
+type RevokeConfig struct {
+	Endpoint      string           // an OpenID Connect endpoint
+	Metadata      ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	ClientID      string           // a client's ID
+	ClientAuth    ClientAuth       // a client's authentication at the revocation endpoint
+	Token         string           // an access token or a refresh token
+	TokenTypeHint string           // a type of the token: TokenTypeAccess or TokenTypeRefresh; optional
+}
+
+// Revoke invalidates a token at the revocation endpoint. The client is authenticated
+// the same way as at the token endpoint.
+//
+// By the specification the revocation endpoint responds with success to an invalid or already revoked token,
+// so the function does not return an error in this case.
+//
+// See https://tools.ietf.org/html/rfc7009.
+func Revoke(ctx context.Context, cnf *RevokeConfig) error {
+	if err := validateTokenRequest(cnf.Endpoint, cnf.ClientID, &cnf.ClientAuth, cnf.Token); err != nil {
+		return err
+	}
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, endpointRevocation)
+	if err != nil {
+		return err
+	}
+
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Revoke the token at the revocation endpoint %q\n", meta.RevocationEndpoint)
+	form := url.Values{}
+	form.Set("token", cnf.Token)
+	if cnf.TokenTypeHint != "" {
+		form.Set("token_type_hint", cnf.TokenTypeHint)
+	}
+	if _, err = sendClientRequest(ctx, meta, meta.RevocationEndpoint, cnf.ClientID, &cnf.ClientAuth, form); err != nil {
+		return err
+	}
+	debugger.Debugln("The token is revoked")
+	return nil
+}
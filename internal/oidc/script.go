@@ -0,0 +1,333 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Actions of a login script's steps.
+const (
+	// ActionNavigate navigates to the step's URL and waits for the page loading.
+	ActionNavigate = "navigate"
+	// ActionWaitForSelector waits until the page contains an element that matches the step's selector.
+	ActionWaitForSelector = "wait-for-selector"
+	// ActionFill types the step's value into an element that matches the step's selector.
+	ActionFill = "fill"
+	// ActionClick clicks an element that matches the step's selector, and optionally waits for the page loading.
+	ActionClick = "click"
+	// ActionSelect selects an option with the step's value in a select element that matches the step's selector.
+	ActionSelect = "select"
+	// ActionCheck checks (or unchecks) a checkbox or a radio button that matches the step's selector.
+	ActionCheck = "check"
+	// ActionAssertText checks that the text of an element that matches the step's selector contains the step's text.
+	ActionAssertText = "assert-text"
+	// ActionEvalJS evaluates the step's JavaScript expression on the page.
+	ActionEvalJS = "eval-js"
+	// ActionBranchIfPresent runs the steps "then" when the page contains an element that matches the step's selector,
+	// and the steps "else" otherwise.
+	ActionBranchIfPresent = "branch-if-present"
+)
+
+// defaultStepTimeout is a timeout of the steps wait-for-selector and click (when it waits for the page loading)
+// that do not define a timeout.
+const defaultStepTimeout = 5 * time.Second
+
+// Script is a login script. The script's steps are run on the login page one by one
+// until the OpenID Connect Provider redirects a user to the client's redirect URI.
+//
+// The values of steps can contain the variables ${username} and ${password} that are replaced
+// with the user's name and password.
+type Script struct {
+	Steps []*Step `json:"steps" yaml:"steps"`
+}
+
+// Step is a step of a login script.
+type Step struct {
+	Action   string  `json:"action" yaml:"action"`                         // a step's action
+	Selector string  `json:"selector,omitempty" yaml:"selector,omitempty"` // a CSS selector of an element
+	Value    string  `json:"value,omitempty" yaml:"value,omitempty"`       // a value to fill or an option to select
+	URL      string  `json:"url,omitempty" yaml:"url,omitempty"`           // an URL to navigate
+	Text     string  `json:"text,omitempty" yaml:"text,omitempty"`         // an expected text of an element
+	JS       string  `json:"js,omitempty" yaml:"js,omitempty"`             // a JavaScript expression
+	Checked  *bool   `json:"checked,omitempty" yaml:"checked,omitempty"`   // a checkbox's state; true by default
+	Wait     bool    `json:"wait,omitempty" yaml:"wait,omitempty"`         // wait for the page loading after a click
+	Timeout  string  `json:"timeout,omitempty" yaml:"timeout,omitempty"`   // a timeout of waiting, for example, "10s"
+	Then     []*Step `json:"then,omitempty" yaml:"then,omitempty"`         // steps to run when an element is present
+	Else     []*Step `json:"else,omitempty" yaml:"else,omitempty"`         // steps to run when an element is absent
+
+	// name is the element's name that is used in error messages instead of the selector.
+	name string
+	// kind is a kind of the error that happens when the page does not contain the element.
+	kind errors.Kind
+}
+
+// LoadScript loads a login script from a file in YAML or JSON format.
+//
+// The format is chosen by the file's extension: ".json" is JSON, and any other extension is YAML.
+func LoadScript(filename string) (*Script, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.New(errors.KindScriptInvalid, err, "read login script")
+	}
+	script := &Script{}
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		err = json.Unmarshal(b, script)
+	} else {
+		err = yaml.UnmarshalStrict(b, script)
+	}
+	if err != nil {
+		return nil, errors.New(errors.KindScriptInvalid, err, "parse login script")
+	}
+	if err = script.validate(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// validate checks that all script's steps have known actions and required parameters.
+func (s *Script) validate() error {
+	if len(s.Steps) == 0 {
+		return errors.New(errors.KindScriptInvalid, "login script does not contain steps")
+	}
+	return validateSteps(s.Steps, "")
+}
+
+func validateSteps(steps []*Step, prefix string) error {
+	for i, st := range steps {
+		pos := fmt.Sprintf("%s%d", prefix, i+1)
+		invalid := func(msg string) error {
+			return errors.New(errors.KindScriptInvalid, "step %s (%s): %s", pos, st.Action, msg)
+		}
+		if st.Timeout != "" {
+			if _, err := time.ParseDuration(st.Timeout); err != nil {
+				return invalid(fmt.Sprintf("invalid timeout %q", st.Timeout))
+			}
+		}
+		switch st.Action {
+		case ActionNavigate:
+			if st.URL == "" {
+				return invalid("url is missed")
+			}
+			continue
+		case ActionEvalJS:
+			if st.JS == "" {
+				return invalid("js is missed")
+			}
+			continue
+		case ActionWaitForSelector, ActionFill, ActionClick, ActionSelect, ActionCheck, ActionAssertText, ActionBranchIfPresent:
+		case "":
+			return invalid("action is missed")
+		default:
+			return invalid("unknown action")
+		}
+		if st.Selector == "" {
+			return invalid("selector is missed")
+		}
+		if st.Action == ActionBranchIfPresent {
+			if err := validateSteps(st.Then, pos+".then."); err != nil {
+				return err
+			}
+			if err := validateSteps(st.Else, pos+".else."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// timeout returns the step's timeout, or a default value when the step does not define it.
+func (st *Step) timeout(def time.Duration) time.Duration {
+	if st.Timeout == "" {
+		return def
+	}
+	d, err := time.ParseDuration(st.Timeout)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// target returns a description of the element that the step works with.
+func (st *Step) target() string {
+	if st.name != "" {
+		return st.name
+	}
+	return fmt.Sprintf("element %q", st.Selector)
+}
+
+// defaultScript returns the built-in login script that fills the username and password, and submits the login form.
+//
+// When the configuration defines the username page's submit button the script fills the username on the first page,
+// submits it, and fills the password on the next page (multi-step login).
+// Otherwise, the username and password are filled on the same page.
+//
+// When the configuration enables the passwordless login by a passkey the script fills the username
+// if the page contains the username field, and clicks the button that starts the passkey ceremony.
+func defaultScript(cnf *LoginConfig) *Script {
+	expect := func(name, sel string, kind errors.Kind, timeout time.Duration) *Step {
+		return &Step{Action: ActionWaitForSelector, Selector: sel, Timeout: timeout.String(), name: name, kind: kind}
+	}
+	if cnf.WebAuthn.enabled() {
+		button := cnf.WebAuthn.Button
+		if button == "" {
+			button = cnf.SubmitButton
+		}
+		return &Script{Steps: []*Step{
+			{
+				Action:   ActionBranchIfPresent,
+				Selector: cnf.UsernameField,
+				Then:     []*Step{{Action: ActionFill, Selector: cnf.UsernameField, Value: "${username}", name: "the username field"}},
+				name:     "the username field",
+			},
+			expect("the passkey button", button, errors.KindWebAuthnButtonInvalid, 0),
+			{Action: ActionClick, Selector: button, Wait: true, name: "the passkey button"},
+		}}
+	}
+	username := expect("the username field", cnf.UsernameField, errors.KindUsernameFieldInvalid, 0)
+	submit := expect("the submit button", cnf.SubmitButton, errors.KindSubmitButtonInvalid, 0)
+	fillUsername := &Step{Action: ActionFill, Selector: cnf.UsernameField, Value: "${username}", name: "the username field"}
+	fillPassword := &Step{Action: ActionFill, Selector: cnf.PasswordField, Value: "${password}", name: "the password field"}
+	clickSubmit := &Step{Action: ActionClick, Selector: cnf.SubmitButton, Wait: true, name: "the submit button"}
+
+	if cnf.UsernameSubmit == "" {
+		return &Script{Steps: []*Step{
+			username,
+			expect("the password field", cnf.PasswordField, errors.KindPasswordFieldInvalid, 0),
+			submit,
+			fillUsername,
+			fillPassword,
+			clickSubmit,
+		}}
+	}
+	// A single-page application shows the password page without loading a new page,
+	// so the script waits for the password field instead of the page loading.
+	return &Script{Steps: []*Step{
+		username,
+		expect("the submit button", cnf.UsernameSubmit, errors.KindSubmitButtonInvalid, 0),
+		fillUsername,
+		{Action: ActionClick, Selector: cnf.UsernameSubmit, name: "the submit button"},
+		expect("the password field", cnf.PasswordField, errors.KindPasswordFieldInvalid, defaultStepTimeout),
+		submit,
+		fillPassword,
+		clickSubmit,
+	}}
+}
+
+// runScript runs steps of a login script on the current page.
+//
+// The variables ${username} and ${password} in steps' values are replaced using vars.
+func runScript(ctx context.Context, steps []*Step, vars *strings.Replacer) error {
+	for i, st := range steps {
+		if err := runStep(ctx, st, vars); err != nil {
+			// Errors of known kinds (for example, a missed username field) are returned as is.
+			if e, ok := err.(*errors.Error); ok && e.Kind != errors.KindOther && e.Kind != errors.KindTimeout {
+				return err
+			}
+			return errors.New(errors.KindScriptStepFailed, err, "step %d (%s)", i+1, st.Action)
+		}
+	}
+	return nil
+}
+
+func runStep(ctx context.Context, st *Step, vars *strings.Replacer) error {
+	debugger := log.DebuggerFromContext(ctx)
+	switch st.Action {
+	case ActionFill, ActionClick, ActionSelect, ActionCheck:
+		// The login form is ready when the script starts to fill it.
+		stepTimerFromContext(ctx).finish(StepFormReady)
+	}
+	switch st.Action {
+	case ActionNavigate:
+		u := vars.Replace(st.URL)
+		debugger.Debugf("Navigate to %q\n", u)
+		return chrome.Navigate(ctx, u)
+
+	case ActionWaitForSelector:
+		debugger.Debugf("Wait for %s\n", st.target())
+		err := chrome.WaitForElement(ctx, st.Selector, st.timeout(defaultStepTimeout))
+		if err != nil && st.kind != errors.KindOther && errors.Match(err, errors.New(errors.KindTimeout)) {
+			return errors.New(st.kind, "the login form does not contains %s", st.target())
+		}
+		return err
+
+	case ActionFill:
+		v := vars.Replace(st.Value)
+		if v == "" {
+			return nil
+		}
+		// The value is not logged because of it can be a password.
+		debugger.Debugf("Fill %s\n", st.target())
+		if err := chromedp.Run(ctx, chromedp.SendKeys(st.Selector, v)); err != nil {
+			return errors.Wrap(err, "fill %s", st.target())
+		}
+		return nil
+
+	case ActionClick:
+		debugger.Debugf("Click %s\n", st.target())
+		if !st.Wait {
+			return chromedp.Run(ctx, chromedp.Click(st.Selector))
+		}
+		wait := chrome.PageLoadWaiterFunc(ctx, false, st.timeout(defaultStepTimeout))
+		if err := chromedp.Run(ctx, chromedp.Click(st.Selector)); err != nil {
+			return errors.Wrap(err, "click %s", st.target())
+		}
+		if err := wait(); err != nil {
+			return errors.Wrap(err, "wait for the page loading")
+		}
+		return nil
+
+	case ActionSelect:
+		v := vars.Replace(st.Value)
+		debugger.Debugf("Select %q in %s\n", v, st.target())
+		return chrome.SelectOption(ctx, st.Selector, v)
+
+	case ActionCheck:
+		checked := st.Checked == nil || *st.Checked
+		debugger.Debugf("Set %s checked to %t\n", st.target(), checked)
+		return chrome.SetChecked(ctx, st.Selector, checked)
+
+	case ActionAssertText:
+		text, err := chrome.Text(ctx, st.Selector)
+		if err != nil {
+			return err
+		}
+		if want := vars.Replace(st.Text); !strings.Contains(text, want) {
+			return errors.New("the text of %s is %q, want it to contain %q", st.target(), strings.TrimSpace(text), want)
+		}
+		return nil
+
+	case ActionEvalJS:
+		debugger.Debugln("Evaluate JavaScript")
+		return chrome.Eval(ctx, st.JS)
+
+	case ActionBranchIfPresent:
+		err := chrome.WaitForElement(ctx, st.Selector, st.timeout(0))
+		if err != nil && !errors.Match(err, errors.New(errors.KindTimeout)) {
+			return err
+		}
+		if err == nil {
+			debugger.Debugf("%s is present\n", st.target())
+			return runScript(ctx, st.Then, vars)
+		}
+		debugger.Debugf("%s is absent\n", st.target())
+		return runScript(ctx, st.Else, vars)
+	}
+	return errors.New(errors.KindScriptInvalid, "unknown action %q", st.Action)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestLoadScript(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+		want    *Script
+		wantErr error
+	}{
+		{
+			name:    "invalid yaml",
+			file:    "script.yaml",
+			content: "steps: [",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "unknown field",
+			file:    "script.yaml",
+			content: "steps:\n  - action: click\n    selektor: '#submit'\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "no steps",
+			file:    "script.yaml",
+			content: "steps: []\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "unknown action",
+			file:    "script.yaml",
+			content: "steps:\n  - action: hover\n    selector: '#menu'\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "selector is missed",
+			file:    "script.yaml",
+			content: "steps:\n  - action: click\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "url is missed",
+			file:    "script.yaml",
+			content: "steps:\n  - action: navigate\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name:    "invalid timeout",
+			file:    "script.yaml",
+			content: "steps:\n  - action: wait-for-selector\n    selector: '#user'\n    timeout: soon\n",
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name: "invalid nested step",
+			file: "script.yaml",
+			content: `
+steps:
+  - action: branch-if-present
+    selector: "#banner"
+    then:
+      - action: fill
+`,
+			wantErr: errors.New(errors.KindScriptInvalid),
+		},
+		{
+			name: "yaml",
+			file: "script.yml",
+			content: `
+steps:
+  - action: branch-if-present
+    selector: "#banner"
+    timeout: 2s
+    then:
+      - action: check
+        selector: "#agree"
+        checked: false
+  - action: fill
+    selector: "#user"
+    value: ${username}
+  - action: click
+    selector: "#submit"
+    wait: true
+`,
+			want: &Script{Steps: []*Step{
+				{
+					Action:   ActionBranchIfPresent,
+					Selector: "#banner",
+					Timeout:  "2s",
+					Then:     []*Step{{Action: ActionCheck, Selector: "#agree", Checked: new(bool)}},
+				},
+				{Action: ActionFill, Selector: "#user", Value: "${username}"},
+				{Action: ActionClick, Selector: "#submit", Wait: true},
+			}},
+		},
+		{
+			name: "json",
+			file: "script.json",
+			content: `{
+				"steps": [
+					{"action": "navigate", "url": "http://op/login"},
+					{"action": "eval-js", "js": "document.title = 'login'"},
+					{"action": "assert-text", "selector": "h1", "text": "Sign in"}
+				]
+			}`,
+			want: &Script{Steps: []*Step{
+				{Action: ActionNavigate, URL: "http://op/login"},
+				{Action: ActionEvalJS, JS: "document.title = 'login'"},
+				{Action: ActionAssertText, Selector: "h1", Text: "Sign in"},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tokget")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, tc.file)
+			if err = ioutil.WriteFile(filename, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadScript(filename)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDefaultScript(t *testing.T) {
+	cnf := &LoginConfig{UsernameField: "#user", PasswordField: "#pass", SubmitButton: "#submit"}
+	actions := func(s *Script) []string {
+		var v []string
+		for _, st := range s.Steps {
+			v = append(v, st.Action+" "+st.Selector)
+		}
+		return v
+	}
+
+	got := actions(defaultScript(cnf))
+	want := []string{
+		"wait-for-selector #user",
+		"wait-for-selector #pass",
+		"wait-for-selector #submit",
+		"fill #user",
+		"fill #pass",
+		"click #submit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("single page: got %q, want %q", got, want)
+	}
+
+	cnf.UsernameSubmit = "#next"
+	got = actions(defaultScript(cnf))
+	want = []string{
+		"wait-for-selector #user",
+		"wait-for-selector #next",
+		"fill #user",
+		"click #next",
+		"wait-for-selector #pass",
+		"wait-for-selector #submit",
+		"fill #pass",
+		"click #submit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("multi-step: got %q, want %q", got, want)
+	}
+	if err := defaultScript(cnf).validate(); err != nil {
+		t.Fatalf("default script is invalid: %s", err)
+	}
+
+	cnf.WebAuthn = WebAuthn{CredentialFile: "passkey.json"}
+	got = actions(defaultScript(cnf))
+	want = []string{
+		"branch-if-present #user",
+		"wait-for-selector #submit",
+		"click #submit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("passkey: got %q, want %q", got, want)
+	}
+	if err := defaultScript(cnf).validate(); err != nil {
+		t.Fatalf("default script is invalid: %s", err)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// tokenResponse is a response of the token endpoint.
+//
+// See https://tools.ietf.org/html/rfc6749#section-5.
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	IDToken      string      `json:"id_token"`
+	RefreshToken string      `json:"refresh_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	Scope        string      `json:"scope"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	ErrorHint        string `json:"error_hint"`
+}
+
+// requestToken sends a token request to the token endpoint on behalf of a client, and returns issued tokens.
+//
+// When the token endpoint responds with an error the function returns an error with the kind errors.KindOIDCError.
+func requestToken(ctx context.Context, tokenEndpoint, clientID string, auth *ClientAuth, form url.Values) (*LoginData, error) {
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Request tokens from the token endpoint %q (grant type %q)\n", tokenEndpoint, form.Get("grant_type"))
+
+	header := http.Header{}
+	if err := auth.apply(clientID, tokenEndpoint, form, header); err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "create token request")
+	}
+	r.Header = header
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+
+	reqCtx, cancelReqCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelReqCtx()
+	resp, err := http.DefaultClient.Do(r.WithContext(reqCtx))
+	if err != nil {
+		return nil, errors.Wrap(err, "send token request")
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read token response")
+	}
+
+	var tokenResp tokenResponse
+	if err = json.Unmarshal(b, &tokenResp); err != nil {
+		return nil, errors.Wrap(err, "parse token response (status code %d)", resp.StatusCode)
+	}
+	if tokenResp.Error != "" {
+		msg := tokenResp.Error
+		if tokenResp.ErrorDescription != "" {
+			msg = tokenResp.ErrorDescription
+		}
+		// error_hint is sent by ORY Hydra Server only.
+		if tokenResp.ErrorHint != "" {
+			msg = fmt.Sprintf("%s: %s", msg, tokenResp.ErrorHint)
+		}
+		return nil, errors.New(errors.KindOIDCError, msg)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("token endpoint responded with status code %d", resp.StatusCode)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, errors.New("the token endpoint does not send an access token")
+	}
+
+	data := &LoginData{
+		AccessToken:  tokenResp.AccessToken,
+		IDToken:      tokenResp.IDToken,
+		RefreshToken: tokenResp.RefreshToken,
+		Scope:        tokenResp.Scope,
+	}
+	if tokenResp.ExpiresIn != "" {
+		if data.ExpiresIn, err = strconv.ParseInt(string(tokenResp.ExpiresIn), 10, 64); err != nil {
+			return nil, errors.Wrap(err, "parse expires_in of the token response")
+		}
+	}
+	debugger.Debugln("Tokens are issued")
+	return data, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestRequestToken(t *testing.T) {
+	testCases := []struct {
+		name    string
+		status  int
+		resp    string
+		want    *LoginData
+		wantErr error
+	}{
+		{
+			name:    "openid connect error",
+			status:  http.StatusBadRequest,
+			resp:    `{"error": "invalid_grant", "error_description": "code is expired"}`,
+			wantErr: errors.New(errors.KindOIDCError),
+		},
+		{
+			name:    "unexpected status code",
+			status:  http.StatusInternalServerError,
+			resp:    `{}`,
+			wantErr: errors.New(errors.KindOther),
+		},
+		{
+			name:    "access token is missed",
+			status:  http.StatusOK,
+			resp:    `{"id_token": "id_token_value"}`,
+			wantErr: errors.New(errors.KindOther),
+		},
+		{
+			name:   "happy path",
+			status: http.StatusOK,
+			resp: `{
+				"access_token": "access_token_value",
+				"id_token": "id_token_value",
+				"refresh_token": "refresh_token_value",
+				"token_type": "bearer",
+				"expires_in": 3600
+			}`,
+			want: &LoginData{
+				AccessToken:  "access_token_value",
+				IDToken:      "id_token_value",
+				RefreshToken: "refresh_token_value",
+				ExpiresIn:    3600,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("grant_type", "authorization_code")
+			form.Set("code", "code_value")
+			wantForm := url.Values{}
+			wantForm.Set("grant_type", "authorization_code")
+			wantForm.Set("code", "code_value")
+			wantForm.Set("client_id", "test-client")
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Fatalf("got method %q, want %q", r.Method, http.MethodPost)
+				}
+				if err := r.ParseForm(); err != nil {
+					t.Fatalf("failed to parse token request: %s", err)
+				}
+				if !reflect.DeepEqual(r.PostForm, wantForm) {
+					t.Fatalf("got form %#v, want form %#v", r.PostForm, wantForm)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				fmt.Fprintln(w, tc.resp)
+			}))
+			defer srv.Close()
+
+			got, err := requestToken(context.Background(), srv.URL, "test-client", &ClientAuth{}, form)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// The test vector from https://tools.ietf.org/html/rfc7636#appendix-B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got := pkceChallenge(verifier); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// UserInfoConfig is a configuration of the UserInfo request.
+type UserInfoConfig struct {
+	Endpoint    string           // an OpenID Connect endpoint
+	Metadata    ProviderMetadata // overrides of the OpenID Connect Provider's metadata
+	AccessToken string           // an access token
+	Subject     string           // an expected subject, for example, the claim "sub" of the user's ID token; not checked when empty
+}
+
+// UserInfo requests claims about the authenticated user from the UserInfo endpoint with an access token.
+//
+// The function supports both plain JSON responses and signed responses (application/jwt).
+// The signature of a signed response is not verified.
+// When the configuration defines the expected subject the function checks that the response's claim "sub" equals to it.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#UserInfo.
+func UserInfo(ctx context.Context, cnf *UserInfoConfig) (map[string]interface{}, error) {
+	if cnf.Endpoint == "" {
+		return nil, errors.New(errors.KindEndpointMissed, "OpenID Connect endpoint is missed")
+	}
+	if _, err := url.Parse(cnf.Endpoint); err != nil {
+		return nil, errors.New(errors.KindEndpointInvalid, "OpenID Connect endpoint has an invalid value")
+	}
+	if cnf.AccessToken == "" {
+		return nil, errors.New(errors.KindAccessTokenMissed, "access token is missed")
+	}
+
+	meta, err := discover(ctx, cnf.Endpoint, &cnf.Metadata, endpointUserinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Request claims from the UserInfo endpoint %q\n", meta.UserinfoEndpoint)
+
+	r, err := http.NewRequest(http.MethodGet, meta.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "create UserInfo request")
+	}
+	r.Header.Set("Authorization", "Bearer "+cnf.AccessToken)
+	r.Header.Set("Accept", "application/json, application/jwt")
+
+	reqCtx, cancelReqCtx := context.WithTimeout(ctx, 10*time.Second)
+	defer cancelReqCtx()
+	resp, err := http.DefaultClient.Do(r.WithContext(reqCtx))
+	if err != nil {
+		return nil, errors.Wrap(err, "send UserInfo request")
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read UserInfo response")
+	}
+
+	// An error of the UserInfo endpoint is sent in the header WWW-Authenticate.
+	// See https://tools.ietf.org/html/rfc6750#section-3.
+	if challenge := resp.Header.Get("WWW-Authenticate"); challenge != "" && resp.StatusCode != http.StatusOK {
+		return nil, errors.New(errors.KindOIDCError, "UserInfo endpoint rejected the access token: %s", challenge)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("UserInfo endpoint responded with status code %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/jwt" {
+		tok, err := jwt.Parse(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, errors.Wrap(err, "parse signed UserInfo response")
+		}
+		claims = tok.Claims
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&claims); err != nil {
+			return nil, errors.Wrap(err, "parse UserInfo response")
+		}
+	}
+	debugger.Debugln("UserInfo claims are received")
+
+	// The response can be about another user because of token substitution, so the subject must be checked.
+	// See https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse.
+	if cnf.Subject != "" {
+		if sub, _ := claims["sub"].(string); sub != cnf.Subject {
+			return nil, errors.New(errors.KindSubjectMismatch, "the UserInfo response's subject %q does not match the expected subject %q", sub, cnf.Subject)
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+)
+
+func TestUserInfo(t *testing.T) {
+	signed, err := jwt.Sign(&jwt.Header{Alg: "HS256"}, map[string]interface{}{"sub": "foo", "email": "foo@example.com"}, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		accessToken string
+		subject     string
+		contentType string
+		resp        string
+		status      int
+		challenge   string
+		want        map[string]interface{}
+		wantErr     error
+	}{
+		{
+			name:    "access token is missed",
+			wantErr: errors.New(errors.KindAccessTokenMissed),
+		},
+		{
+			name:        "access token is rejected",
+			accessToken: "expired",
+			status:      http.StatusUnauthorized,
+			challenge:   `Bearer error="invalid_token", error_description="The access token expired"`,
+			wantErr:     errors.New(errors.KindOIDCError),
+		},
+		{
+			name:        "server error",
+			accessToken: "valid",
+			status:      http.StatusInternalServerError,
+			wantErr:     errors.New(errors.KindOther),
+		},
+		{
+			name:        "JSON response",
+			accessToken: "valid",
+			contentType: "application/json; charset=utf-8",
+			resp:        `{"sub": "foo", "email_verified": true, "updated_at": 1556712000}`,
+			status:      http.StatusOK,
+			want:        map[string]interface{}{"sub": "foo", "email_verified": true, "updated_at": json.Number("1556712000")},
+		},
+		{
+			name:        "signed response",
+			accessToken: "valid",
+			contentType: "application/jwt",
+			resp:        signed,
+			status:      http.StatusOK,
+			want:        map[string]interface{}{"sub": "foo", "email": "foo@example.com"},
+		},
+		{
+			name:        "subject matches",
+			accessToken: "valid",
+			subject:     "foo",
+			contentType: "application/json",
+			resp:        `{"sub": "foo"}`,
+			status:      http.StatusOK,
+			want:        map[string]interface{}{"sub": "foo"},
+		},
+		{
+			name:        "subject mismatch",
+			accessToken: "valid",
+			subject:     "foo",
+			contentType: "application/json",
+			resp:        `{"sub": "bar"}`,
+			status:      http.StatusOK,
+			wantErr:     errors.New(errors.KindSubjectMismatch),
+		},
+		{
+			name:        "subject is missed",
+			accessToken: "valid",
+			subject:     "foo",
+			contentType: "application/json",
+			resp:        `{"email": "foo@example.com"}`,
+			status:      http.StatusOK,
+			wantErr:     errors.New(errors.KindSubjectMismatch),
+		},
+		{
+			name:        "subject mismatch in signed response",
+			accessToken: "valid",
+			subject:     "bar",
+			contentType: "application/jwt",
+			resp:        signed,
+			status:      http.StatusOK,
+			wantErr:     errors.New(errors.KindSubjectMismatch),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/.well-known/openid-configuration" {
+					fmt.Fprintf(w, `{"issuer": "http://%s", "userinfo_endpoint": "http://%s/userinfo"}`, r.Host, r.Host)
+					return
+				}
+				if got, want := r.Header.Get("Authorization"), "Bearer "+tc.accessToken; got != want {
+					t.Errorf("got authorization %q, want %q", got, want)
+				}
+				if tc.challenge != "" {
+					w.Header().Set("WWW-Authenticate", tc.challenge)
+				}
+				w.Header().Set("Content-Type", tc.contentType)
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.resp)
+			}))
+			defer srv.Close()
+
+			got, err := UserInfo(context.Background(), &UserInfoConfig{Endpoint: srv.URL, AccessToken: tc.accessToken, Subject: tc.subject})
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
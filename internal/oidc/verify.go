@@ -0,0 +1,166 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// verifyLeeway is an allowed clock skew between tokget and an OpenID Connect Provider.
+const verifyLeeway = time.Minute
+
+// timeNow returns the current time. Tests replace it to check time-dependent claims.
+var timeNow = time.Now
+
+// jwksCache keeps loaded JSON Web Key Sets during the program's run.
+var jwksCache = struct {
+	sync.Mutex
+	sets map[string]*jwt.JWKS
+}{sets: make(map[string]*jwt.JWKS)}
+
+// loadJWKS loads a JSON Web Key Set from an URL.
+//
+// A loaded key set is cached for the program's run.
+func loadJWKS(ctx context.Context, jwksURI string) (*jwt.JWKS, error) {
+	jwksCache.Lock()
+	defer jwksCache.Unlock()
+
+	if jwks, ok := jwksCache.sets[jwksURI]; ok {
+		return jwks, nil
+	}
+
+	debugger := log.DebuggerFromContext(ctx)
+	debugger.Debugf("Load the JWK Set %q\n", jwksURI)
+	b, err := fetch(ctx, jwksURI, nil)
+	if err != nil {
+		return nil, errors.New(errors.KindJWKSFailed, err, "load the JWK Set")
+	}
+	jwks, err := jwt.ParseJWKS(b)
+	if err != nil {
+		return nil, errors.New(errors.KindJWKSFailed, err, "load the JWK Set")
+	}
+
+	jwksCache.sets[jwksURI] = jwks
+	return jwks, nil
+}
+
+// idTokenChecks contains expected values of an ID token's claims.
+type idTokenChecks struct {
+	issuer      string
+	clientID    string
+	nonce       string
+	accessToken string
+	implicit    bool // the tokens are issued in the implicit flow
+}
+
+// verifyIDToken checks an ID token's signature with a key from the provider's JWK Set, and validates the token's claims.
+//
+// See https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation.
+func verifyIDToken(ctx context.Context, jwksURI, idToken string, checks *idTokenChecks) error {
+	if idToken == "" {
+		return errors.New(errors.KindIDTokenInvalid, "the OpenID Connect Provider does not issue an ID token")
+	}
+	tok, err := jwt.Parse(idToken)
+	if err != nil {
+		return errors.New(errors.KindIDTokenInvalid, err, "parse ID token")
+	}
+
+	//
+	// Check the signature.
+	//
+	jwks, err := loadJWKS(ctx, jwksURI)
+	if err != nil {
+		return err
+	}
+	if err = verifySignature(tok, jwks); err != nil {
+		return err
+	}
+
+	//
+	// Check the claims.
+	//
+	if iss := tok.String("iss"); iss != checks.issuer {
+		return errors.New(errors.KindIssuerMismatch, "the ID token's issuer %q does not match the OpenID Connect Provider's issuer %q", iss, checks.issuer)
+	}
+	aud := tok.Audience()
+	if !contains(aud, checks.clientID) {
+		return errors.New(errors.KindAudienceMismatch, "the ID token's audience %q does not contain the client ID %q", aud, checks.clientID)
+	}
+	azp := tok.String("azp")
+	if azp == "" && len(aud) > 1 {
+		return errors.New(errors.KindAuthorizedPartyMismatch, "the ID token has multiple audiences but does not contain the claim azp")
+	}
+	if azp != "" && azp != checks.clientID {
+		return errors.New(errors.KindAuthorizedPartyMismatch, "the ID token's authorized party %q does not match the client ID %q", azp, checks.clientID)
+	}
+	now := timeNow()
+	exp, ok := tok.Int("exp")
+	if !ok {
+		return errors.New(errors.KindIDTokenInvalid, "the ID token does not contain the claim exp")
+	}
+	if expTime := time.Unix(exp, 0); now.After(expTime.Add(verifyLeeway)) {
+		return errors.New(errors.KindTokenExpired, "the ID token expired at %s", expTime.UTC().Format(time.RFC3339))
+	}
+	iat, ok := tok.Int("iat")
+	if !ok {
+		return errors.New(errors.KindIDTokenInvalid, "the ID token does not contain the claim iat")
+	}
+	if iatTime := time.Unix(iat, 0); iatTime.After(now.Add(verifyLeeway)) {
+		return errors.New(errors.KindIssuedAtInvalid, "the ID token is issued in the future at %s", iatTime.UTC().Format(time.RFC3339))
+	}
+	if err = checkNonce(idToken, checks.nonce); err != nil {
+		return err
+	}
+	// The claim at_hash is required in the implicit flow when an access token is issued,
+	// and optional in the authorization code flow, so in the latter it is checked when it presents.
+	//
+	// See https://openid.net/specs/openid-connect-core-1_0.html#ImplicitIDToken.
+	atHash := tok.String("at_hash")
+	if atHash == "" && checks.implicit && checks.accessToken != "" {
+		return errors.New(errors.KindAtHashMissed, "the ID token does not contain the claim at_hash that is required in the implicit flow")
+	}
+	if atHash != "" && checks.accessToken != "" {
+		want, err := tok.LeftHash(checks.accessToken)
+		if err != nil {
+			return errors.New(errors.KindIDTokenInvalid, err, "calculate at_hash")
+		}
+		if atHash != want {
+			return errors.New(errors.KindAtHashMismatch, "the ID token's at_hash does not match the access token")
+		}
+	}
+	return nil
+}
+
+// verifySignature checks that a token is signed by one of keys of a JWK Set.
+func verifySignature(tok *jwt.Token, jwks *jwt.JWKS) error {
+	keys := jwks.Candidates(tok.Header)
+	if len(keys) == 0 {
+		return errors.New(errors.KindSignatureInvalid, "the JWK Set does not contain a key for algorithm %q and key ID %q", tok.Header.Alg, tok.Header.Kid)
+	}
+	var err error
+	for _, key := range keys {
+		if err = tok.Verify(key); err == nil {
+			return nil
+		}
+	}
+	return errors.New(errors.KindSignatureInvalid, err, "the token's signature is invalid")
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
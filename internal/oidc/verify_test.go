@@ -0,0 +1,227 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestVerifyIDToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := jwt.JWKS{Keys: []jwt.JWK{
+		{Kty: "RSA", Kid: "rsa", Use: "sig", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())},
+		{Kty: "OKP", Kid: "ed", Crv: "Ed25519", X: b64(edKey.Public().(ed25519.PublicKey))},
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewEncoder(w).Encode(jwks); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1500000000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	const (
+		issuer      = "https://issuer"
+		clientID    = "client"
+		nonce       = "nonce"
+		accessToken = "access-token"
+	)
+	sign := func(alg, kid string, key interface{}, modify func(claims map[string]interface{})) string {
+		claims := map[string]interface{}{
+			"iss":   issuer,
+			"sub":   "user",
+			"aud":   clientID,
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": nonce,
+		}
+		// at_hash depends on the algorithm so it is calculated by a token with the same header.
+		tok := &jwt.Token{Header: &jwt.Header{Alg: alg}}
+		atHash, err := tok.LeftHash(accessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims["at_hash"] = atHash
+		if modify != nil {
+			modify(claims)
+		}
+		raw, err := jwt.Sign(&jwt.Header{Alg: alg, Typ: "JWT", Kid: kid}, claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	testCases := []struct {
+		name     string
+		idToken  string
+		implicit bool
+		wantErr  error
+	}{
+		{
+			name:    "id token is missed",
+			wantErr: errors.New(errors.KindIDTokenInvalid),
+		},
+		{
+			name:    "id token is malformed",
+			idToken: "not-a-token",
+			wantErr: errors.New(errors.KindIDTokenInvalid),
+		},
+		{
+			name:    "unknown key id",
+			idToken: sign("RS256", "unknown", rsaKey, nil),
+			wantErr: errors.New(errors.KindSignatureInvalid),
+		},
+		{
+			name:    "foreign key",
+			idToken: sign("RS256", "rsa", foreignKey, nil),
+			wantErr: errors.New(errors.KindSignatureInvalid),
+		},
+		{
+			name:    "issuer mismatch",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["iss"] = "https://other" }),
+			wantErr: errors.New(errors.KindIssuerMismatch),
+		},
+		{
+			name:    "audience mismatch",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["aud"] = "other" }),
+			wantErr: errors.New(errors.KindAudienceMismatch),
+		},
+		{
+			name:    "multiple audiences without azp",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["aud"] = []string{clientID, "other"} }),
+			wantErr: errors.New(errors.KindAuthorizedPartyMismatch),
+		},
+		{
+			name:    "azp mismatch",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["azp"] = "other" }),
+			wantErr: errors.New(errors.KindAuthorizedPartyMismatch),
+		},
+		{
+			name:    "token expired",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["exp"] = now.Add(-2 * time.Minute).Unix() }),
+			wantErr: errors.New(errors.KindTokenExpired),
+		},
+		{
+			name:    "token issued in the future",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["iat"] = now.Add(2 * time.Minute).Unix() }),
+			wantErr: errors.New(errors.KindIssuedAtInvalid),
+		},
+		{
+			name:    "nonce mismatch",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["nonce"] = "other" }),
+			wantErr: errors.New(errors.KindNonceMismatch),
+		},
+		{
+			name:    "at_hash mismatch",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["at_hash"] = "other" }),
+			wantErr: errors.New(errors.KindAtHashMismatch),
+		},
+		{
+			name:     "at_hash missed in the implicit flow",
+			idToken:  sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { delete(c, "at_hash") }),
+			implicit: true,
+			wantErr:  errors.New(errors.KindAtHashMissed),
+		},
+		{
+			name:    "at_hash missed in the authorization code flow",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { delete(c, "at_hash") }),
+		},
+		{
+			name:     "at_hash in the implicit flow",
+			idToken:  sign("RS256", "rsa", rsaKey, nil),
+			implicit: true,
+		},
+		{
+			name:    "clock skew",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) { c["exp"] = now.Add(-30 * time.Second).Unix() }),
+		},
+		{
+			name: "multiple audiences with azp",
+			idToken: sign("RS256", "rsa", rsaKey, func(c map[string]interface{}) {
+				c["aud"] = []string{clientID, "other"}
+				c["azp"] = clientID
+			}),
+		},
+		{
+			name:    "RS256",
+			idToken: sign("RS256", "rsa", rsaKey, nil),
+		},
+		{
+			name:    "PS256",
+			idToken: sign("PS256", "rsa", rsaKey, nil),
+		},
+		{
+			name:    "ES256",
+			idToken: sign("ES256", "ec", ecKey, nil),
+		},
+		{
+			name:    "EdDSA",
+			idToken: sign("EdDSA", "ed", edKey, nil),
+		},
+		{
+			name:    "key without id",
+			idToken: sign("ES256", "", ecKey, nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := &idTokenChecks{issuer: issuer, clientID: clientID, nonce: nonce, accessToken: accessToken, implicit: tc.implicit}
+			err := verifyIDToken(context.Background(), srv.URL, tc.idToken, checks)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+		})
+	}
+}
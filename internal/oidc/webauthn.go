@@ -0,0 +1,178 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/i-core/tokget/internal/atomicfile"
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/jwt"
+	"github.com/i-core/tokget/internal/log"
+)
+
+// WebAuthn is a configuration of the passwordless login by a passkey (WebAuthn).
+//
+// A stored credential is added to a virtual authenticator that is attached to Chrome's tab,
+// so the OpenID Connect Provider's passkey ceremony is completed without any hardware.
+type WebAuthn struct {
+	CredentialFile string // a path to a file that contains a stored WebAuthn credential
+	Button         string // a CSS selector of the button that starts the passkey ceremony on the login page
+}
+
+// enabled returns true when a credential file is defined.
+func (w *WebAuthn) enabled() bool {
+	return w.CredentialFile != ""
+}
+
+// webAuthnCredentialFile is a stored WebAuthn credential.
+//
+// The credential's ID and the user's handle are encoded in base64 (standard or URL-safe, with or without padding).
+// The private key is an ECDSA P-256 key in PEM or JWK format, or a PKCS #8 key in base64
+// (as Chrome DevTools exports it).
+type webAuthnCredentialFile struct {
+	CredentialID string `json:"credential_id"`
+	RPID         string `json:"rp_id"`
+	UserHandle   string `json:"user_handle,omitempty"`
+	PrivateKey   string `json:"private_key"`
+	SignCount    int64  `json:"sign_count"`
+}
+
+// loadWebAuthnCredential loads a stored WebAuthn credential from a file in JSON format.
+func loadWebAuthnCredential(filename string) (*webAuthnCredentialFile, *chrome.WebAuthnCredential, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, errors.New(errors.KindWebAuthnCredentialInvalid, err, "read WebAuthn credential")
+	}
+	var stored webAuthnCredentialFile
+	if err = json.Unmarshal(b, &stored); err != nil {
+		return nil, nil, errors.New(errors.KindWebAuthnCredentialInvalid, err, "parse WebAuthn credential")
+	}
+	cred, err := stored.credential()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &stored, cred, nil
+}
+
+// credential decodes the stored credential to a credential of a virtual authenticator.
+func (f *webAuthnCredentialFile) credential() (*chrome.WebAuthnCredential, error) {
+	invalid := func(err error, msg string) error {
+		return errors.New(errors.KindWebAuthnCredentialInvalid, err, msg)
+	}
+	if f.CredentialID == "" {
+		return nil, errors.New(errors.KindWebAuthnCredentialInvalid, "WebAuthn credential's ID is missed")
+	}
+	if f.RPID == "" {
+		return nil, errors.New(errors.KindWebAuthnCredentialInvalid, "WebAuthn credential's relying party ID is missed")
+	}
+	if f.PrivateKey == "" {
+		return nil, errors.New(errors.KindWebAuthnCredentialInvalid, "WebAuthn credential's private key is missed")
+	}
+	cred := &chrome.WebAuthnCredential{RPID: f.RPID, SignCount: f.SignCount}
+	var err error
+	if cred.ID, err = decodeBase64(f.CredentialID); err != nil {
+		return nil, invalid(err, "invalid WebAuthn credential's ID")
+	}
+	if f.UserHandle != "" {
+		if cred.UserHandle, err = decodeBase64(f.UserHandle); err != nil {
+			return nil, invalid(err, "invalid WebAuthn credential's user handle")
+		}
+	}
+	if cred.PrivateKey, err = webAuthnPrivateKey(f.PrivateKey); err != nil {
+		return nil, invalid(err, "invalid WebAuthn credential's private key")
+	}
+	return cred, nil
+}
+
+// webAuthnPrivateKey returns an ECDSA P-256 private key in PKCS #8 DER format.
+func webAuthnPrivateKey(v string) ([]byte, error) {
+	var key interface{}
+	if strings.HasPrefix(strings.TrimSpace(v), "-----BEGIN") || strings.HasPrefix(strings.TrimSpace(v), "{") {
+		var err error
+		if key, _, err = jwt.ParsePrivateKey([]byte(v)); err != nil {
+			return nil, err
+		}
+	} else {
+		der, err := decodeBase64(v)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = x509.ParsePKCS8PrivateKey(der); err != nil {
+			return nil, errors.Wrap(err, "parse PKCS #8 private key")
+		}
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, errors.New("the key is not an ECDSA P-256 key")
+	}
+	return x509.MarshalPKCS8PrivateKey(ecKey)
+}
+
+// decodeBase64 decodes a value in base64 (standard or URL-safe, with or without padding).
+func decodeBase64(v string) ([]byte, error) {
+	v = strings.TrimRight(strings.TrimSpace(v), "=")
+	v = strings.NewReplacer("+", "-", "/", "_").Replace(v)
+	return base64.RawURLEncoding.DecodeString(v)
+}
+
+// saveSignCount writes the signature counter of a virtual authenticator's credential to the credential file.
+//
+// Relying parties can reject an assertion whose counter is not greater than the previous one,
+// so the counter is stored for the next login. The file is replaced atomically to not lose the private key
+// if the write fails.
+func saveSignCount(ctx context.Context, filename string, stored *webAuthnCredentialFile, authenticatorID string) error {
+	creds, err := chrome.WebAuthnCredentials(ctx, authenticatorID)
+	if err != nil {
+		return err
+	}
+	if !stored.updateSignCount(creds) {
+		return nil
+	}
+	log.DebuggerFromContext(ctx).Debugf("Save the WebAuthn credential's signature counter %d\n", stored.SignCount)
+	b, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode WebAuthn credential")
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return errors.Wrap(err, "save WebAuthn credential")
+	}
+	if err = atomicfile.WriteFile(filename, append(b, '\n'), fi.Mode().Perm()); err != nil {
+		return errors.Wrap(err, "save WebAuthn credential")
+	}
+	return nil
+}
+
+// updateSignCount takes the signature counter of the stored credential from the authenticator's credentials,
+// and returns true when the counter is increased.
+//
+// The authenticator can contain other credentials, for example, when the relying party registers a new one
+// during the ceremony, so the credential is found by its ID.
+func (f *webAuthnCredentialFile) updateSignCount(creds []*chrome.WebAuthnCredential) bool {
+	id, err := decodeBase64(f.CredentialID)
+	if err != nil {
+		return false
+	}
+	for _, cred := range creds {
+		if bytes.Equal(cred.ID, id) && cred.SignCount > f.SignCount {
+			f.SignCount = cred.SignCount
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/i-core/tokget/internal/chrome"
+	"github.com/i-core/tokget/internal/errors"
+)
+
+func TestLoadWebAuthnCredential(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSEC1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384DER, err := x509.MarshalPKCS8PrivateKey(p384Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	pkcs8PEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}))
+	sec1PEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecSEC1}))
+	id, handle := []byte{0xfb, 0xff, 0x01, 0x02}, []byte("user-1")
+
+	testCases := []struct {
+		name    string
+		stored  *webAuthnCredentialFile
+		want    *chrome.WebAuthnCredential
+		wantErr error
+	}{
+		{
+			name:    "credential id is missed",
+			stored:  &webAuthnCredentialFile{RPID: "op", PrivateKey: pkcs8PEM},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name:    "rp id is missed",
+			stored:  &webAuthnCredentialFile{CredentialID: "AQI", PrivateKey: pkcs8PEM},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name:    "private key is missed",
+			stored:  &webAuthnCredentialFile{CredentialID: "AQI", RPID: "op"},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name:    "invalid credential id",
+			stored:  &webAuthnCredentialFile{CredentialID: "!!", RPID: "op", PrivateKey: pkcs8PEM},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name:    "rsa key",
+			stored:  &webAuthnCredentialFile{CredentialID: "AQI", RPID: "op", PrivateKey: string(rsaPEM)},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name: "p-384 key",
+			stored: &webAuthnCredentialFile{
+				CredentialID: "AQI",
+				RPID:         "op",
+				PrivateKey:   base64.StdEncoding.EncodeToString(p384DER),
+			},
+			wantErr: errors.New(errors.KindWebAuthnCredentialInvalid),
+		},
+		{
+			name: "pkcs8 pem",
+			stored: &webAuthnCredentialFile{
+				CredentialID: base64.RawURLEncoding.EncodeToString(id),
+				RPID:         "op",
+				UserHandle:   base64.RawURLEncoding.EncodeToString(handle),
+				PrivateKey:   pkcs8PEM,
+				SignCount:    7,
+			},
+			want: &chrome.WebAuthnCredential{ID: id, RPID: "op", PrivateKey: ecDER, UserHandle: handle, SignCount: 7},
+		},
+		{
+			name: "sec1 pem",
+			stored: &webAuthnCredentialFile{
+				CredentialID: base64.StdEncoding.EncodeToString(id),
+				RPID:         "op",
+				PrivateKey:   sec1PEM,
+			},
+			want: &chrome.WebAuthnCredential{ID: id, RPID: "op", PrivateKey: ecDER},
+		},
+		{
+			name: "pkcs8 base64",
+			stored: &webAuthnCredentialFile{
+				CredentialID: base64.StdEncoding.EncodeToString(id),
+				RPID:         "op",
+				UserHandle:   base64.StdEncoding.EncodeToString(handle),
+				PrivateKey:   base64.StdEncoding.EncodeToString(ecDER),
+			},
+			want: &chrome.WebAuthnCredential{ID: id, RPID: "op", PrivateKey: ecDER, UserHandle: handle},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tokget")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "passkey.json")
+			b, err := json.Marshal(tc.stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = ioutil.WriteFile(filename, b, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, got, err := loadWebAuthnCredential(filename)
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSignCount(t *testing.T) {
+	id := []byte{0xfb, 0xff, 0x01, 0x02}
+	other := []byte{0x03, 0x04}
+
+	testCases := []struct {
+		name        string
+		creds       []*chrome.WebAuthnCredential
+		want        int64
+		wantUpdated bool
+	}{
+		{
+			name:        "increased counter",
+			creds:       []*chrome.WebAuthnCredential{{ID: id, SignCount: 6}},
+			want:        6,
+			wantUpdated: true,
+		},
+		{
+			name:  "same counter",
+			creds: []*chrome.WebAuthnCredential{{ID: id, SignCount: 5}},
+			want:  5,
+		},
+		{
+			name:  "counter of another credential",
+			creds: []*chrome.WebAuthnCredential{{ID: other, SignCount: 10}},
+			want:  5,
+		},
+		{
+			name:        "another credential is registered",
+			creds:       []*chrome.WebAuthnCredential{{ID: other, SignCount: 10}, {ID: id, SignCount: 7}},
+			want:        7,
+			wantUpdated: true,
+		},
+		{
+			name: "no credentials",
+			want: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stored := &webAuthnCredentialFile{CredentialID: base64.RawURLEncoding.EncodeToString(id), SignCount: 5}
+			if updated := stored.updateSignCount(tc.creds); updated != tc.wantUpdated {
+				t.Errorf("got updated %v, want %v", updated, tc.wantUpdated)
+			}
+			if stored.SignCount != tc.want {
+				t.Errorf("got signature counter %d, want %d", stored.SignCount, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package output writes the result of the login in various formats.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+// Output formats.
+const (
+	// FormatJSON is a JSON object of the login data.
+	FormatJSON = "json"
+	// FormatEnv is shell commands that export the tokens as environment variables.
+	FormatEnv = "env"
+	// FormatDotenv is a .env file with the tokens.
+	FormatDotenv = "dotenv"
+	// FormatRawAccess is the access token only.
+	FormatRawAccess = "raw-access"
+	// FormatRawID is the ID token only.
+	FormatRawID = "raw-id"
+	// FormatHeader is an HTTP header Authorization with the access token.
+	FormatHeader = "header"
+	// FormatTemplate is a Go template that is executed with the login data.
+	FormatTemplate = "template"
+)
+
+// Printer writes the login data in an output format.
+type Printer struct {
+	format string
+	tmpl   *template.Template
+}
+
+// New returns a printer of an output format. The template is required by the format FormatTemplate only.
+// The format and template are validated before the login to not waste it.
+func New(format, text string) (*Printer, error) {
+	p := &Printer{format: format}
+	switch format {
+	case FormatJSON, FormatEnv, FormatDotenv, FormatRawAccess, FormatRawID, FormatHeader:
+		if text != "" {
+			return nil, errors.New(errors.KindOutputInvalid, "template is allowed with output format %q only", FormatTemplate)
+		}
+	case FormatTemplate:
+		if text == "" {
+			return nil, errors.New(errors.KindOutputInvalid, "template is missed")
+		}
+		tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, errors.New(errors.KindOutputInvalid, err, "parse template")
+		}
+		p.tmpl = tmpl
+	default:
+		return nil, errors.New(errors.KindOutputInvalid, "output format %q is not supported", format)
+	}
+	return p, nil
+}
+
+// Print writes the login data to w.
+func (p *Printer) Print(w io.Writer, data *oidc.LoginData) error {
+	switch p.format {
+	case FormatJSON:
+		b, err := json.Marshal(data)
+		if err != nil {
+			return errors.Wrap(err, "encode user data to JSON")
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case FormatEnv, FormatDotenv:
+		for _, v := range variables(data) {
+			var err error
+			if p.format == FormatEnv {
+				_, err = fmt.Fprintf(w, "export %s=%s\n", v[0], shellQuote(v[1]))
+			} else {
+				_, err = fmt.Fprintf(w, "%s=%s\n", v[0], dotenvQuote(v[1]))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatRawAccess:
+		_, err := fmt.Fprintln(w, data.AccessToken)
+		return err
+	case FormatRawID:
+		_, err := fmt.Fprintln(w, data.IDToken)
+		return err
+	case FormatHeader:
+		_, err := fmt.Fprintf(w, "Authorization: Bearer %s\n", data.AccessToken)
+		return err
+	case FormatTemplate:
+		if err := p.tmpl.Execute(w, data); err != nil {
+			return errors.New(errors.KindOutputInvalid, err, "execute template")
+		}
+		return nil
+	}
+	return errors.New(errors.KindOutputInvalid, "output format %q is not supported", p.format)
+}
+
+// variables returns names and values of environment variables with the login data. Empty values are skipped.
+func variables(data *oidc.LoginData) [][2]string {
+	var vars [][2]string
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, [2]string{name, value})
+		}
+	}
+	add("ACCESS_TOKEN", data.AccessToken)
+	add("ID_TOKEN", data.IDToken)
+	add("REFRESH_TOKEN", data.RefreshToken)
+	if data.ExpiresIn != 0 {
+		add("EXPIRES_IN", strconv.FormatInt(data.ExpiresIn, 10))
+	}
+	return vars
+}
+
+// isPlain returns true when a value does not need quoting in a shell and .env file.
+func isPlain(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-_.~+/=:@%,", r):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// shellQuote quotes a value for a POSIX shell if it is needed.
+func shellQuote(s string) string {
+	if isPlain(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// dotenvQuote quotes a value for a .env file if it is needed.
+func dotenvQuote(s string) string {
+	if isPlain(s) {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/i-core/tokget/internal/errors"
+	"github.com/i-core/tokget/internal/oidc"
+)
+
+func TestPrinter(t *testing.T) {
+	data := &oidc.LoginData{AccessToken: "at.1", IDToken: "it.2", ExpiresIn: 3600}
+
+	testCases := []struct {
+		name     string
+		format   string
+		template string
+		data     *oidc.LoginData
+		want     string
+		wantErr  error
+	}{
+		{
+			name:    "unknown format",
+			format:  "yaml",
+			wantErr: errors.New(errors.KindOutputInvalid),
+		},
+		{
+			name:     "template without the format template",
+			format:   FormatJSON,
+			template: "{{.AccessToken}}",
+			wantErr:  errors.New(errors.KindOutputInvalid),
+		},
+		{
+			name:    "format template without a template",
+			format:  FormatTemplate,
+			wantErr: errors.New(errors.KindOutputInvalid),
+		},
+		{
+			name:     "invalid template",
+			format:   FormatTemplate,
+			template: "{{.AccessToken",
+			wantErr:  errors.New(errors.KindOutputInvalid),
+		},
+		{
+			name:     "template with an unknown field",
+			format:   FormatTemplate,
+			template: "{{.Token}}",
+			wantErr:  errors.New(errors.KindOutputInvalid),
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			want:   `{"access_token":"at.1","id_token":"it.2","expires_in":3600}` + "\n",
+		},
+		{
+			name:   "env",
+			format: FormatEnv,
+			want:   "export ACCESS_TOKEN=at.1\nexport ID_TOKEN=it.2\nexport EXPIRES_IN=3600\n",
+		},
+		{
+			name:   "env with quoting",
+			format: FormatEnv,
+			data:   &oidc.LoginData{AccessToken: "it's opaque", IDToken: "it.2", RefreshToken: "rt"},
+			want:   "export ACCESS_TOKEN='it'\\''s opaque'\nexport ID_TOKEN=it.2\nexport REFRESH_TOKEN=rt\n",
+		},
+		{
+			name:   "dotenv",
+			format: FormatDotenv,
+			data:   &oidc.LoginData{AccessToken: `a "$b"`, IDToken: "it.2"},
+			want:   "ACCESS_TOKEN=\"a \\\"\\$b\\\"\"\nID_TOKEN=it.2\n",
+		},
+		{
+			name:   "raw access token",
+			format: FormatRawAccess,
+			want:   "at.1\n",
+		},
+		{
+			name:   "raw ID token",
+			format: FormatRawID,
+			want:   "it.2\n",
+		},
+		{
+			name:   "header",
+			format: FormatHeader,
+			want:   "Authorization: Bearer at.1\n",
+		},
+		{
+			name:     "template",
+			format:   FormatTemplate,
+			template: "token={{.AccessToken}} expires_in={{.ExpiresIn}}",
+			want:     "token=at.1 expires_in=3600",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := tc.data
+			if d == nil {
+				d = data
+			}
+			var buf bytes.Buffer
+			p, err := New(tc.format, tc.template)
+			if err == nil {
+				err = p.Print(&buf, d)
+			}
+
+			if tc.wantErr != nil {
+				if err == nil {
+					t.Fatalf("\ngot no errors\nwant error:\n\t%s", tc.wantErr)
+				}
+				if !errors.Match(err, tc.wantErr) {
+					t.Fatalf("\ngot error:\n\t%s\nwant error:\n\t%s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\ngot error:\n\t%s\nwant no errors", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("\ngot:\n%q\nwant:\n%q", got, tc.want)
+			}
+		})
+	}
+}
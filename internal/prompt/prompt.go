@@ -0,0 +1,23 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package prompt reads secrets from the terminal.
+package prompt
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Password prints a prompt, and reads a secret, without echo, from the stdin.
+//
+// The prompt is written to stderr because of stdout contains the command's result.
+func Password(prompt string) ([]byte, error) {
+	fmt.Fprintln(os.Stderr, prompt)
+	return terminal.ReadPassword(0)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+// Package totp generates Time-Based One-Time Passwords.
+//
+// See https://tools.ietf.org/html/rfc6238.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/i-core/tokget/internal/errors"
+)
+
+const (
+	// Period is a time step of a one-time password.
+	Period = 30 * time.Second
+	// Digits is a number of digits of a one-time password.
+	Digits = 6
+)
+
+// DecodeSecret decodes a shared secret in base32 format.
+//
+// The function ignores spaces, the letter case and the padding because of authenticator apps show secrets in different forms.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	s = strings.TrimRight(s, "=")
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode base32 secret")
+	}
+	if len(b) == 0 {
+		return nil, errors.New("secret is empty")
+	}
+	return b, nil
+}
+
+// Counter returns the number of the time step that contains a time.
+func Counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period/time.Second))
+}
+
+// Code returns a one-time password for a time step's number by HOTP with HMAC-SHA-1.
+//
+// See https://tools.ietf.org/html/rfc4226#section-5.3.
+func Code(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, v%mod)
+}
+
+// Remaining returns the time that remains until the end of the time step that contains a time.
+func Remaining(t time.Time) time.Duration {
+	next := time.Unix(int64(Counter(t)+1)*int64(Period/time.Second), 0)
+	return next.Sub(t)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (c) JSC iCore.
+This source code is licensed under the MIT license found in the
+LICENSE file in the root directory of this source tree.
+*/
+
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// The test vectors for SHA-1 from https://tools.ietf.org/html/rfc6238#appendix-B
+	// truncated to 6 digits.
+	secret, err := DecodeSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+	if err != nil {
+		t.Fatalf("failed to decode secret: %s", err)
+	}
+	testCases := []struct {
+		time int64
+		want string
+	}{
+		{time: 59, want: "287082"},
+		{time: 1111111109, want: "081804"},
+		{time: 1111111111, want: "050471"},
+		{time: 1234567890, want: "005924"},
+		{time: 2000000000, want: "279037"},
+		{time: 20000000000, want: "353130"},
+	}
+	for _, tc := range testCases {
+		if got := Code(secret, Counter(time.Unix(tc.time, 0))); got != tc.want {
+			t.Errorf("time %d: got %q, want %q", tc.time, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	testCases := []struct {
+		secret  string
+		want    string
+		wantErr bool
+	}{
+		{secret: "GEZDGNBV", want: "12345"},
+		{secret: "gezd gnbv", want: "12345"},
+		{secret: "GEZDGNBVGY======", want: "123456"},
+		{secret: "1", wantErr: true},
+		{secret: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := DecodeSecret(tc.secret)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("secret %q: got no errors, want error", tc.secret)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("secret %q: got error %s", tc.secret, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("secret %q: got %q, want %q", tc.secret, got, tc.want)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	if got, want := Remaining(time.Unix(59, 0)), time.Second; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := Remaining(time.Unix(60, 0)), Period; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}